@@ -5,7 +5,9 @@ go 1.16
 require (
 	github.com/alexedwards/scs/v2 v2.4.0
 	github.com/go-chi/chi v1.5.4
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.8
 	github.com/rs/zerolog v1.23.0
-	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97 // indirect
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97
 )
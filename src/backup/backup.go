@@ -0,0 +1,411 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/migrations"
+	_ "github.com/mattn/go-sqlite3"
+	zerolog "github.com/rs/zerolog/log"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimeLayout is the layout of the time in the name of a snapshot; it sorts like the time
+const TimeLayout = "20060102T150405.000Z"
+
+var snapshotName = regexp.MustCompile(`^snapshot-(\d{8}T\d{6}\.\d{3}Z)\.db(\.gz)?$`)
+
+// ErrNoSnapshot returned when no snapshot matches the requested time
+var ErrNoSnapshot = errors.New("there is no snapshot at or before this time")
+
+// Snapshot holding a backup file of the sqlite database
+type Snapshot struct {
+	File      string    `json:"file"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	Gzip      bool      `json:"gzip"`
+	// Version is the schema version of the snapshot; it is only known after the snapshot is verified
+	Version int `json:"schema_version,omitempty"`
+}
+
+// Manager is taking snapshots of DB into Dir and restoring them into DB
+type Manager struct {
+	DB  *sql.DB
+	Dir string
+	// Gzip compresses the new snapshots
+	Gzip bool
+	// Keep is the number of the newest snapshots that are kept and MaxAge removes the older snapshots;
+	// zero turns them off
+	Keep   int
+	MaxAge time.Duration
+}
+
+// NewManager use for creating a Manager of the sqlite database db
+func NewManager(db *sql.DB, dir string, gzip bool, keep int, maxAge time.Duration) *Manager {
+	return &Manager{
+		DB:     db,
+		Dir:    dir,
+		Gzip:   gzip,
+		Keep:   keep,
+		MaxAge: maxAge,
+	}
+}
+
+// Backup use for taking a verified snapshot of DB by the online backup api and removing the snapshots
+// that the retention policy does not keep
+func (m *Manager) Backup(ctx context.Context) (*Snapshot, error) {
+	snap, err := m.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.applyRetention(snap)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+	}
+
+	return snap, nil
+}
+
+// snapshot use for copying DB into a temporary file, checking the copy and moving it to its name;
+// a failed snapshot leaves no file
+func (m *Manager) snapshot(ctx context.Context) (*Snapshot, error) {
+	err := os.MkdirAll(m.Dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := tempPath(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	now := time.Now().UTC()
+	err = withDatabase(tmp, func(dst *sql.DB) error {
+		return copyDatabase(ctx, dst, m.DB)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := Verify(ctx, tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		File:      filepath.Join(m.Dir, "snapshot-"+now.Format(TimeLayout)+".db"),
+		CreatedAt: now,
+		Gzip:      m.Gzip,
+		Version:   version,
+	}
+	if m.Gzip {
+		snap.File += ".gz"
+		err = compress(tmp, snap.File)
+	} else {
+		err = os.Rename(tmp, snap.File)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(snap.File)
+	if err != nil {
+		return nil, err
+	}
+	snap.Size = info.Size()
+
+	return snap, nil
+}
+
+// Snapshots use for listing the snapshots of Dir from the oldest to the newest
+func (m *Manager) Snapshots() ([]*Snapshot, error) {
+	entries, err := ioutil.ReadDir(m.Dir)
+	if os.IsNotExist(err) {
+		return []*Snapshot{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var snaps []*Snapshot = []*Snapshot{}
+	for _, e := range entries {
+		match := snapshotName.FindStringSubmatch(e.Name())
+		if match == nil || e.IsDir() {
+			continue
+		}
+
+		createdAt, err := time.Parse(TimeLayout, match[1])
+		if err != nil {
+			continue
+		}
+
+		snaps = append(snaps, &Snapshot{
+			File:      filepath.Join(m.Dir, e.Name()),
+			CreatedAt: createdAt,
+			Size:      e.Size(),
+			Gzip:      match[2] != "",
+		})
+	}
+
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].CreatedAt.Before(snaps[j].CreatedAt)
+	})
+
+	return snaps, nil
+}
+
+// Find use for getting the newest snapshot that is taken at or before at
+func (m *Manager) Find(at time.Time) (*Snapshot, error) {
+	snaps, err := m.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if !snaps[i].CreatedAt.After(at) {
+			return snaps[i], nil
+		}
+	}
+
+	return nil, ErrNoSnapshot
+}
+
+// applyRetention use for removing the snapshots after the Keep newest ones and the snapshots older than MaxAge;
+// keep is never removed
+func (m *Manager) applyRetention(keep *Snapshot) error {
+	snaps, err := m.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for i, snap := range snaps {
+		tooMany := m.Keep > 0 && i < len(snaps)-m.Keep
+		tooOld := m.MaxAge > 0 && now.Sub(snap.CreatedAt) > m.MaxAge
+		if snap.File == keep.File || (!tooMany && !tooOld) {
+			continue
+		}
+
+		err = os.Remove(snap.File)
+		if err != nil {
+			return err
+		}
+		zerolog.Info().Msg("snapshot " + snap.File + " is removed by the retention policy")
+	}
+
+	return nil
+}
+
+// Restore use for replacing the content of DB by a snapshot; the snapshot is verified before and DB after the copy.
+// A snapshot with a newer schema than this build knows is rejected, an older one is migrated at the next start.
+// The current content of DB is saved as a new snapshot first, which the retention policy does not remove now
+func (m *Manager) Restore(ctx context.Context, file string) (*Snapshot, *Snapshot, error) {
+	err := os.MkdirAll(m.Dir, 0700)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmp, err := tempPath(m.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(tmp)
+
+	if strings.HasSuffix(file, ".gz") {
+		err = decompress(file, tmp)
+	} else {
+		err = copyFile(file, tmp)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s can not be read: %w", file, err)
+	}
+
+	version, err := Verify(ctx, tmp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s can not be restored: %w", file, err)
+	}
+
+	saved, err := m.snapshot(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("saving the current database: %w", err)
+	}
+
+	err = withDatabase(tmp, func(src *sql.DB) error {
+		return copyDatabase(ctx, m.DB, src)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	restoredVersion, err := check(ctx, m.DB)
+	if err == nil && restoredVersion != version {
+		err = fmt.Errorf("the restored database has schema version %d instead of %d", restoredVersion, version)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w; %s has the database before the restore", err, saved.File)
+	}
+
+	restored := &Snapshot{File: file, Version: version, Gzip: strings.HasSuffix(file, ".gz")}
+	if match := snapshotName.FindStringSubmatch(filepath.Base(file)); match != nil {
+		restored.CreatedAt, _ = time.Parse(TimeLayout, match[1])
+	}
+
+	return restored, saved, nil
+}
+
+// Verify use for checking the sqlite database of path with PRAGMA integrity_check and returning its schema
+// version; the version must be one of the migrations that this build knows
+func Verify(ctx context.Context, path string) (int, error) {
+	var version int
+	err := withDatabase(path, func(db *sql.DB) error {
+		var err error
+		version, err = check(ctx, db)
+		return err
+	})
+
+	return version, err
+}
+
+// check use for running PRAGMA integrity_check on db and returning its schema version
+func check(ctx context.Context, db *sql.DB) (int, error) {
+	var result string
+	err := db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result)
+	if err != nil {
+		return 0, err
+	}
+	if result != "ok" {
+		return 0, errors.New("integrity check failed: " + result)
+	}
+
+	return schemaVersion(ctx, db)
+}
+
+// schemaVersion use for reading the migration version of db and checking this build can run it
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	m, err := migrations.New(db, migrations.DriverSQLite)
+	if err != nil {
+		return 0, err
+	}
+
+	version, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, errors.New("the database has no applied migrations")
+	}
+	if version > m.Latest() {
+		return 0, fmt.Errorf("the database has schema version %d but this build only knows up to %d", version, m.Latest())
+	}
+
+	return version, nil
+}
+
+// withDatabase use for opening the sqlite database of path with one connection for fn
+func withDatabase(path string, fn func(db *sql.DB) error) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(1)
+
+	err = fn(db)
+	closeErr := db.Close()
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+func tempPath(dir string) (string, error) {
+	f, err := ioutil.TempFile(dir, ".snapshot-*.tmp")
+	if err != nil {
+		return "", err
+	}
+
+	return f.Name(), f.Close()
+}
+
+// compress use for writing the gzip of src into dst; dst only appears when it is complete
+func compress(src, dst string) error {
+	return writeAtomic(dst, src, func(w io.Writer, r io.Reader) error {
+		zw := gzip.NewWriter(w)
+		_, err := io.Copy(zw, r)
+		if err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+func decompress(src, dst string) error {
+	return writeFile(dst, src, func(w io.Writer, r io.Reader) error {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+
+		_, err = io.Copy(w, zr)
+		return err
+	})
+}
+
+func copyFile(src, dst string) error {
+	return writeFile(dst, src, func(w io.Writer, r io.Reader) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+// writeAtomic use for writing dst by a temporary file that is renamed to dst when it is written and synced
+func writeAtomic(dst, src string, copyFn func(w io.Writer, r io.Reader) error) error {
+	tmp, err := tempPath(filepath.Dir(dst))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	err = writeFile(tmp, src, copyFn)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+// writeFile use for writing the content of src into dst by copyFn and syncing dst
+func writeFile(dst, src string, copyFn func(w io.Writer, r io.Reader) error) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = copyFn(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	closeErr := out.Close()
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
@@ -0,0 +1,69 @@
+//go:build cgo
+// +build cgo
+
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"time"
+)
+
+const (
+	// pagesPerStep is the number of pages that a backup step copies while it holds the read lock of the source
+	pagesPerStep = 256
+	// stepPause lets the writers of the source run between the steps
+	stepPause = 5 * time.Millisecond
+)
+
+// copyDatabase use for copying the main database of src into dst by the online backup api of sqlite;
+// src is copied in steps, so the other connections of src can write while it runs and a step that sees
+// their writes starts the copy again
+func copyDatabase(ctx context.Context, dst, src *sql.DB) error {
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return dstConn.Raw(func(dstRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			dstSQLite, ok := dstRaw.(*sqlite3.SQLiteConn)
+			srcSQLite, ok2 := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return errors.New("backups need sqlite connections")
+			}
+
+			b, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+
+			for {
+				done, err := b.Step(pagesPerStep)
+				if err != nil {
+					_ = b.Finish()
+					return err
+				}
+				if done {
+					return b.Finish()
+				}
+
+				select {
+				case <-ctx.Done():
+					_ = b.Finish()
+					return ctx.Err()
+				case <-time.After(stepPause):
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,15 @@
+//go:build !cgo
+// +build !cgo
+
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// copyDatabase use for builds without cgo; they have no sqlite, so there is nothing to copy
+func copyDatabase(ctx context.Context, dst, src *sql.DB) error {
+	return errors.New("backups need a build with cgo for sqlite")
+}
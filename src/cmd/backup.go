@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/backup"
+	"github.com/DapperBlondie/users-cars-systems/src/config"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"strings"
+	"time"
+)
+
+// openBackups use for opening the sqlite database of the db flag with a backup.Manager of it
+func openBackups(ctx context.Context) (*backup.Manager, func() error, error) {
+	dsn := conf.DB.DSN
+	if repo.DriverFor(dsn) != repo.DriverSQLite || dsn == config.StoreMemory || strings.HasPrefix(dsn, config.StoreBolt) {
+		return nil, nil, errors.New("backups are only available for the sqlite backend")
+	}
+
+	dbh, err := openDriver(ctx, conf.DB)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return newBackupManager(dbh), dbh.Dispose, nil
+}
+
+// newBackupManager use for creating the backup.Manager of a sqlite DBHolder by the backup flags
+func newBackupManager(dbh *repo.DBHolder) *backup.Manager {
+	return backup.NewManager(dbh.DB, conf.Backup.Dir, conf.Backup.Gzip, conf.Backup.Keep, conf.Backup.MaxAge)
+}
+
+// runBackup use for the backup subcommand; it takes a snapshot or lists the snapshots with list
+func runBackup(args []string) error {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "list") {
+		flag.Usage()
+		return errors.New("backup takes only list")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	m, closeDB, err := openBackups(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if len(args) == 1 {
+		snaps, err := m.Snapshots()
+		if err != nil {
+			return err
+		}
+		for _, snap := range snaps {
+			fmt.Printf("%s  %10d bytes  %s\n", snap.CreatedAt.Format(time.RFC3339), snap.Size, snap.File)
+		}
+		return nil
+	}
+
+	snap, err := m.Backup(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is written with schema version %d (%d bytes)\n", snap.File, snap.Version, snap.Size)
+
+	return nil
+}
+
+// runRestore use for the restore subcommand; its argument is a snapshot file, latest or a RFC 3339 time
+// that restores the newest snapshot taken at or before it. Stop the api before a restore
+func runRestore(args []string) error {
+	if len(args) != 1 {
+		flag.Usage()
+		return errors.New("restore needs a snapshot file, latest or a RFC 3339 time")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	m, closeDB, err := openBackups(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	file := args[0]
+	at, timeErr := time.Parse(time.RFC3339, args[0])
+	if args[0] == "latest" {
+		at, timeErr = time.Now().UTC(), nil
+	}
+	if timeErr == nil {
+		snap, err := m.Find(at)
+		if err != nil {
+			return err
+		}
+		file = snap.File
+	}
+
+	restored, saved, err := m.Restore(ctx, file)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("the database before the restore is saved as %s\n", saved.File)
+	fmt.Printf("%s is restored with schema version %d; newer migrations are applied at the next start\n", restored.File, restored.Version)
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/migrations"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/boltdb"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/conformance"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/memory"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// runConformance use for the conformance subcommand; it runs the conformance cases against the in memory
+// backend, against sqlite and bbolt files in a temporary directory and against the postgres of args when it is given.
+// Every postgres case starts and ends with the migrations rolled back, so use a database only for this
+func runConformance(args []string) error {
+	if len(args) > 1 || (len(args) == 1 && repo.DriverFor(args[0]) != repo.DriverPostgres) {
+		flag.Usage()
+		return errors.New("conformance takes only a postgres:// dsn")
+	}
+
+	dir, err := ioutil.TempDir("", "ucs-conformance")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	backends := []struct {
+		name string
+		open conformance.Open
+	}{
+		{"memory", func() (repo.ApiOpsInterface, func(), error) {
+			return memory.NewStore(), func() {}, nil
+		}},
+		{"sqlite", func() (repo.ApiOpsInterface, func(), error) {
+			f, err := ioutil.TempFile(dir, "*.db")
+			if err != nil {
+				return nil, nil, err
+			}
+			_ = f.Close()
+
+			dbConf := *conf.DB
+			dbConf.DSN = f.Name()
+			dbh, err := openDriver(ctx, &dbConf)
+			if err != nil {
+				return nil, nil, err
+			}
+			return dbh, func() { _ = dbh.Dispose() }, nil
+		}},
+		{"bolt", func() (repo.ApiOpsInterface, func(), error) {
+			f, err := ioutil.TempFile(dir, "*.bolt")
+			if err != nil {
+				return nil, nil, err
+			}
+			_ = f.Close()
+
+			store, err := boltdb.Open(f.Name())
+			if err != nil {
+				return nil, nil, err
+			}
+			return store, func() { _ = store.Close() }, nil
+		}},
+	}
+	if len(args) == 1 {
+		backends = append(backends, struct {
+			name string
+			open conformance.Open
+		}{"postgres", func() (repo.ApiOpsInterface, func(), error) {
+			return openEmptyPostgres(ctx, args[0])
+		}})
+	}
+
+	failed := 0
+	for _, b := range backends {
+		failures := conformance.Run(ctx, b.open)
+		for _, f := range failures {
+			fmt.Printf("FAIL  %-7s %s: %v\n", b.name, f.Case, f.Err)
+		}
+		fmt.Printf("%-7s %d/%d cases passed\n", b.name, len(conformance.Cases)-len(failures), len(conformance.Cases))
+		failed += len(failures)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d conformance cases failed", failed)
+	}
+
+	return nil
+}
+
+// openEmptyPostgres use for opening the postgres of dsn with no rows; the migrations are rolled back before
+// and after the case
+func openEmptyPostgres(ctx context.Context, dsn string) (repo.ApiOpsInterface, func(), error) {
+	dbh, err := repo.NewDriver(dsn, conf.DB.DriverOptions())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := migrations.New(dbh.DB, dbh.Driver)
+	if err == nil {
+		err = m.To(ctx, 0)
+	}
+	if err == nil {
+		err = m.Up(ctx)
+	}
+	if err == nil {
+		err = dbh.PrepareStatements(ctx)
+	}
+	if err != nil {
+		_ = dbh.Dispose()
+		return nil, nil, err
+	}
+
+	return dbh, func() {
+		err := m.To(ctx, 0)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		_ = dbh.Dispose()
+	}, nil
+}
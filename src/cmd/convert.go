@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/boltdb"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runConvert use for the convert subcommand; it copies the sqlite database of args into a temporary file,
+// migrates the copy to the latest version and copies the copy into a new bbolt file with the same ids, so the
+// api can run with -db bolt:<file>. The database of args is only read and keeps its schema version
+func runConvert(args []string) error {
+	if len(args) != 2 {
+		flag.Usage()
+		return errors.New("convert needs the path of the sqlite database and the path of the new bbolt file")
+	}
+	if repo.DriverFor(args[0]) != repo.DriverSQLite {
+		return errors.New("convert only reads sqlite databases")
+	}
+
+	_, err := os.Stat(args[1])
+	if err == nil {
+		return fmt.Errorf("%s already exists", args[1])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	tmpDir, err := ioutil.TempDir("", "convert-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	source := filepath.Join(tmpDir, "source.db")
+	err = copySQLite(ctx, args[0], source)
+	if err != nil {
+		return err
+	}
+
+	dbConf := *conf.DB
+	dbConf.DSN = source
+	dbh, err := openDriver(ctx, &dbConf)
+	if err != nil {
+		return err
+	}
+	defer dbh.Dispose()
+
+	store, err := boltdb.Open(args[1])
+	if err != nil {
+		return err
+	}
+
+	counts, err := store.ImportSQL(ctx, dbh.DB)
+	closeErr := store.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(args[1])
+		return err
+	}
+
+	for _, table := range []string{"users", "cars", "api_keys", "refresh_tokens", "password_resets", "user_totp",
+		"recovery_codes", "auth_failures", "sessions"} {
+		fmt.Printf("%-16s %d rows\n", table, counts[table])
+	}
+
+	return nil
+}
+
+// copySQLite use for copying the sqlite database of src into the new file dst by VACUUM INTO; src is opened
+// read-only, so nothing is written to it and the rows that are still in its WAL are in the copy
+func copySQLite(ctx context.Context, src, dst string) error {
+	db, err := sql.Open(repo.DriverSQLite, "file:"+src+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, `VACUUM INTO ?`, dst)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+
+	return nil
+}
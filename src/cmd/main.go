@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"github.com/DapperBlondie/users-cars-systems/src/backup"
+	"github.com/DapperBlondie/users-cars-systems/src/config"
 	"github.com/DapperBlondie/users-cars-systems/src/handlers"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/notify"
+	"github.com/DapperBlondie/users-cars-systems/src/passwords"
 	"github.com/DapperBlondie/users-cars-systems/src/repo"
 	"github.com/DapperBlondie/users-cars-systems/src/routes"
+	"github.com/DapperBlondie/users-cars-systems/src/tokens"
 	"github.com/alexedwards/scs/v2"
 	zerolog "github.com/rs/zerolog/log"
 	"net/http"
@@ -13,15 +21,35 @@ import (
 )
 
 const (
-	HOST   = "localhost"
-	PORT   = ":9090"
-	DBNAME = "./app-db.db"
+	HOST = "localhost"
+	PORT = ":9090"
 )
 
 var session *scs.SessionManager
 
+var adminID = flag.Int("admin", 0, "promote the user with this id to admin at startup")
+
+var conf = config.New()
+
 func main() {
-	err := runApp()
+	conf.RegisterFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+
+	var err error
+	if flag.Arg(0) == "migrate" {
+		err = runMigrate(flag.Args()[1:])
+	} else if flag.Arg(0) == "conformance" {
+		err = runConformance(flag.Args()[1:])
+	} else if flag.Arg(0) == "convert" {
+		err = runConvert(flag.Args()[1:])
+	} else if flag.Arg(0) == "backup" {
+		err = runBackup(flag.Args()[1:])
+	} else if flag.Arg(0) == "restore" {
+		err = runRestore(flag.Args()[1:])
+	} else {
+		err = runApp()
+	}
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return
@@ -30,25 +58,69 @@ func main() {
 
 // runApp a function for creating our app with entire configuration
 func runApp() error {
+	err := conf.Validate()
+	if err != nil {
+		return err
+	}
+
+	if conf.Auth.TokensEnabled() && conf.Auth.TokenSecret == "" {
+		zerolog.Warn().Msg("UCS_TOKEN_SECRET is empty; access tokens are signed by a random key and are invalid after restart")
+	}
+	signer, err := tokens.NewSigner(conf.Auth.TokenAlg, conf.Auth.TokenSecret)
+	if err != nil {
+		return err
+	}
+
+	store, sessionStore, closeStore, err := openStore(context.Background(), conf.DB)
+	if err != nil {
+		zerolog.Fatal().Msg(err.Error())
+		return err
+	}
+
+	if *adminID != 0 {
+		err = store.SetUserRole(context.Background(), *adminID, models.RoleAdmin)
+		if err != nil {
+			zerolog.Fatal().Msg(err.Error())
+			return err
+		}
+	}
+
 	session = scs.New()
+	session.Store = sessionStore
 	session.Lifetime = 24 * time.Hour
 	session.Cookie.Persist = true
 	session.Cookie.SameSite = http.SameSiteLaxMode
 	session.Cookie.Secure = false
 
-	dbh, err := repo.NewDriver(DBNAME)
+	policy, err := passwords.NewPolicy(conf.Password.MinLength,
+		conf.Password.RequireUpper,
+		conf.Password.RequireLower,
+		conf.Password.RequireDigit,
+		conf.Password.RequireSymbol,
+		conf.Password.BreachedList)
 	if err != nil {
 		zerolog.Fatal().Msg(err.Error())
 		return err
 	}
 
-	err = dbh.CreateTables()
+	hasher, err := passwords.NewHasher(conf.Password.HashAlg, conf.Password.BcryptCost, conf.Password.Argon2idParams())
 	if err != nil {
 		zerolog.Fatal().Msg(err.Error())
 		return err
 	}
 
-	handlers.NewApiConf(session, dbh)
+	var notifier notify.Notifier = notify.NewLogNotifier()
+	if conf.Auth.Notifier == config.NotifierFile {
+		notifier = notify.NewFileNotifier(conf.Auth.NotifierFile)
+	}
+
+	// only a sqlite database has snapshots; the handlers answer that backups are not available for the others
+	var backups *backup.Manager
+	if dbh, ok := store.(*repo.DBHolder); ok && dbh.Driver == repo.DriverSQLite {
+		backups = newBackupManager(dbh)
+	}
+
+	handlers.NewApiConf(session, store, conf.Auth, conf.Lockout, tokens.NewManager(signer, conf.Auth.AccessTTL), notifier, policy, hasher, backups)
 
 	srv := &http.Server{
 		Addr:              HOST + PORT,
@@ -71,7 +143,7 @@ func runApp() error {
 	}()
 
 	<-sigC
-	err = dbh.Dispose()
+	err = closeStore()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/config"
+	"github.com/DapperBlondie/users-cars-systems/src/migrations"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// usage use for printing the flags with the migrate subcommand
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage:\n")
+	fmt.Fprintf(out, "  %s [flags]                    run the api\n", os.Args[0])
+	fmt.Fprintf(out, "  %s migrate status             list the migrations and when they are applied\n", os.Args[0])
+	fmt.Fprintf(out, "  %s migrate up [version]       apply the pending migrations up to version or the latest\n", os.Args[0])
+	fmt.Fprintf(out, "  %s migrate down [version]     roll back to version or roll back the newest migration\n", os.Args[0])
+	fmt.Fprintf(out, "  %s conformance [postgres-dsn] check that every backend behaves like sqlite\n", os.Args[0])
+	fmt.Fprintf(out, "  %s convert <sqlite> <bbolt>   copy a sqlite database into a new bbolt file\n", os.Args[0])
+	fmt.Fprintf(out, "  %s backup [list]              take a snapshot of the sqlite database or list the snapshots\n", os.Args[0])
+	fmt.Fprintf(out, "  %s restore <file|latest|time> restore a verified snapshot; stop the api first\n", os.Args[0])
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// migrateUp use for applying the pending migrations at startup
+func migrateUp(dbh *repo.DBHolder) error {
+	m, err := migrations.New(dbh.DB, dbh.Driver)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*25)
+	defer cancel()
+
+	return m.Up(ctx)
+}
+
+// runMigrate use for the migrate subcommand
+func runMigrate(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		flag.Usage()
+		return errors.New("migrate needs status, up or down")
+	}
+
+	target := -1
+	if len(args) == 2 {
+		var err error
+		target, err = strconv.Atoi(args[1])
+		if err != nil || target < 0 {
+			return fmt.Errorf("version %q is not a migration version", args[1])
+		}
+	}
+
+	if conf.DB.DSN == config.StoreMemory || strings.HasPrefix(conf.DB.DSN, config.StoreBolt) {
+		return errors.New("only the sql backends have migrations")
+	}
+
+	dbh, err := repo.NewDriver(conf.DB.DSN, conf.DB.DriverOptions())
+	if err != nil {
+		return err
+	}
+	defer dbh.Dispose()
+
+	m, err := migrations.New(dbh.DB, dbh.Driver)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*25)
+	defer cancel()
+
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+	case "up":
+		if target == -1 {
+			target = m.Latest()
+		}
+		if target < version {
+			return fmt.Errorf("version %d is older than the database version %d; use migrate down", target, version)
+		}
+		err = m.To(ctx, target)
+	case "down":
+		if target == -1 {
+			err = m.Down(ctx)
+			break
+		}
+		if target > version {
+			return fmt.Errorf("version %d is newer than the database version %d; use migrate up", target, version)
+		}
+		err = m.To(ctx, target)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = "applied " + s.AppliedAt.Local().Format(time.RFC3339)
+		}
+		fmt.Printf("%04d  %-28s %s\n", s.Version, s.Name, applied)
+	}
+
+	return nil
+}
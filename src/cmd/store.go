@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"github.com/DapperBlondie/users-cars-systems/src/config"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/boltdb"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/memory"
+	"github.com/alexedwards/scs/v2"
+	"strings"
+	"time"
+)
+
+// openStore use for opening the backend of the db flag with a session store that keeps the sessions beside
+// its data; the returned function releases the backend at the end of program
+func openStore(ctx context.Context, dbConf *config.DBConfig) (repo.ApiOpsInterface, scs.Store, func() error, error) {
+	if dbConf.DSN == config.StoreMemory {
+		store := memory.NewStore()
+		sessionStore := store.NewSessionStore(5 * time.Minute)
+		return store, sessionStore, func() error {
+			sessionStore.StopCleanup()
+			return nil
+		}, nil
+	}
+	if strings.HasPrefix(dbConf.DSN, config.StoreBolt) {
+		store, err := boltdb.Open(strings.TrimPrefix(dbConf.DSN, config.StoreBolt))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		sessionStore := store.NewSessionStore(5 * time.Minute)
+		return store, sessionStore, func() error {
+			sessionStore.StopCleanup()
+			return store.Close()
+		}, nil
+	}
+
+	dbh, err := openDriver(ctx, dbConf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return dbh, dbh.NewSessionStore(5 * time.Minute), dbh.Dispose, nil
+}
+
+// openDriver use for opening the sql database with its driver options checked, its migrations applied
+// and its statements prepared
+func openDriver(ctx context.Context, dbConf *config.DBConfig) (*repo.DBHolder, error) {
+	dbh, err := repo.NewDriver(dbConf.DSN, dbConf.DriverOptions())
+	if err != nil {
+		return nil, err
+	}
+	dbh.Timeouts = repo.Timeouts{Read: dbConf.ReadTimeout, Write: dbConf.WriteTimeout, List: dbConf.ListTimeout}
+
+	err = dbh.CheckOptions(ctx, dbConf.DriverOptions())
+	if err != nil {
+		_ = dbh.Dispose()
+		return nil, err
+	}
+
+	err = migrateUp(dbh)
+	if err != nil {
+		_ = dbh.Dispose()
+		return nil, err
+	}
+
+	err = dbh.PrepareStatements(ctx)
+	if err != nil {
+		_ = dbh.Dispose()
+		return nil, err
+	}
+
+	return dbh, nil
+}
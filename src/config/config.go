@@ -0,0 +1,337 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/passwords"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"time"
+)
+
+// Authentication modes of the api
+const (
+	AuthModeSession = "session"
+	AuthModeToken   = "token"
+	AuthModeBoth    = "both"
+)
+
+// Signing algorithms of access tokens
+const (
+	TokenAlgHS256 = "HS256"
+	TokenAlgEdDSA = "EdDSA"
+)
+
+// Notifiers that deliver password reset tokens
+const (
+	NotifierLog  = "log"
+	NotifierFile = "file"
+)
+
+// StoreMemory is the DSN of the backend that keeps everything in memory; it is empty after every restart
+const StoreMemory = "memory:"
+
+// StoreBolt is the prefix of the DSN of the pure go bbolt backend; the path of its file follows the prefix
+const StoreBolt = "bolt:"
+
+// Config holding the entire configuration of the app
+type Config struct {
+	Auth     *AuthConfig
+	Password *PasswordConfig
+	Lockout  *LockoutConfig
+	DB       *DBConfig
+	Backup   *BackupConfig
+}
+
+// AuthConfig holding the configuration of sessions and tokens
+type AuthConfig struct {
+	Mode string
+	// TokenAlg is HS256 or EdDSA
+	TokenAlg string
+	// TokenSecret is the HS256 secret or the base64 encoded Ed25519 seed; it is read from UCS_TOKEN_SECRET
+	TokenSecret string
+	AccessTTL   time.Duration
+	RefreshTTL  time.Duration
+	// ResetTTL is the lifetime of password reset tokens
+	ResetTTL time.Duration
+	// Notifier is log or file; NotifierFile is the path that the file notifier writes into
+	Notifier     string
+	NotifierFile string
+	// RequireStaffMFA denies admins and fleet managers that did not pass two factor authentication
+	RequireStaffMFA bool
+}
+
+// PasswordConfig holding the policy that every new password must pass and how passwords are hashed
+type PasswordConfig struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// BreachedList is a file with one breached password per line; empty means the built-in list
+	BreachedList string
+	// HashAlg is bcrypt or argon2id; hashes of the other algorithm or of older parameters are replaced on login
+	HashAlg    string
+	BcryptCost int
+	// Argon2Memory is in KiB
+	Argon2Time    uint
+	Argon2Memory  uint
+	Argon2Threads uint
+}
+
+// LockoutConfig holding the brute-force protection of authentication
+type LockoutConfig struct {
+	// FreeAttempts and FreeIPAttempts are the failures of a user or an ip before the backoff starts;
+	// many users can share an ip so it is allowed more
+	FreeAttempts   int
+	FreeIPAttempts int
+	// BaseDelay is doubled for every failure after FreeAttempts until MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxUserFailures and MaxIPFailures are the failures that lock a user or an ip for LockoutDuration
+	MaxUserFailures int
+	MaxIPFailures   int
+	LockoutDuration time.Duration
+	// FailureWindow is the time after the last failure that the failures are forgotten
+	FailureWindow time.Duration
+}
+
+// DBConfig holding the backend and the timeouts of the repository operations
+type DBConfig struct {
+	// DSN is a postgres:// url, the path of the sqlite database, StoreBolt followed by the path of a bbolt file
+	// or StoreMemory for a backend that keeps everything in memory
+	DSN string
+	// ReadTimeout is for reading one row, WriteTimeout for inserts, updates and deletes and ListTimeout for listing many rows
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	ListTimeout  time.Duration
+	// SQLiteJournalMode, SQLiteSynchronous, SQLiteBusyTimeout, SQLiteForeignKeys and SQLiteCacheSize are the
+	// settings of every sqlite connection; they are checked at startup
+	SQLiteJournalMode string
+	SQLiteSynchronous string
+	SQLiteBusyTimeout time.Duration
+	SQLiteForeignKeys bool
+	SQLiteCacheSize   int
+	// MaxOpenConns and MaxIdleConns limit the connection pool of sqlite and postgres
+	MaxOpenConns int
+	MaxIdleConns int
+}
+
+// BackupConfig holding where the snapshots of the sqlite database are written and how long they are kept
+type BackupConfig struct {
+	Dir  string
+	Gzip bool
+	// Keep is the number of the newest snapshots that are kept and MaxAge removes older snapshots; zero turns them off
+	Keep   int
+	MaxAge time.Duration
+}
+
+// New use for creating the Config with its default values
+func New() *Config {
+	return &Config{
+		Auth: &AuthConfig{
+			Mode:            AuthModeSession,
+			TokenAlg:        TokenAlgHS256,
+			TokenSecret:     os.Getenv("UCS_TOKEN_SECRET"),
+			AccessTTL:       15 * time.Minute,
+			RefreshTTL:      30 * 24 * time.Hour,
+			ResetTTL:        30 * time.Minute,
+			Notifier:        NotifierLog,
+			NotifierFile:    "./notifications.log",
+			RequireStaffMFA: true,
+		},
+		Password: &PasswordConfig{
+			MinLength:     10,
+			RequireUpper:  false,
+			RequireLower:  true,
+			RequireDigit:  true,
+			HashAlg:       passwords.AlgBcrypt,
+			BcryptCost:    12,
+			Argon2Time:    3,
+			Argon2Memory:  64 * 1024,
+			Argon2Threads: 2,
+		},
+		Lockout: &LockoutConfig{
+			FreeAttempts:    3,
+			FreeIPAttempts:  20,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			MaxUserFailures: 10,
+			MaxIPFailures:   100,
+			LockoutDuration: 15 * time.Minute,
+			FailureWindow:   time.Hour,
+		},
+		DB: &DBConfig{
+			DSN:          "./app-db.db",
+			ReadTimeout:  6 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			ListTimeout:  25 * time.Second,
+
+			SQLiteJournalMode: repo.DefaultDriverOptions.JournalMode,
+			SQLiteSynchronous: repo.DefaultDriverOptions.Synchronous,
+			SQLiteBusyTimeout: repo.DefaultDriverOptions.BusyTimeout,
+			SQLiteForeignKeys: repo.DefaultDriverOptions.ForeignKeys,
+			SQLiteCacheSize:   repo.DefaultDriverOptions.CacheSize,
+			MaxOpenConns:      repo.DefaultDriverOptions.MaxOpenConns,
+			MaxIdleConns:      repo.DefaultDriverOptions.MaxIdleConns,
+		},
+		Backup: &BackupConfig{
+			Dir:  "./backups",
+			Gzip: true,
+			Keep: 14,
+		},
+	}
+}
+
+// RegisterFlags use for binding the Config fields to command line flags
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Auth.Mode, "auth-mode", c.Auth.Mode, "authentication mode: session, token or both")
+	fs.StringVar(&c.Auth.TokenAlg, "token-alg", c.Auth.TokenAlg, "access token signing algorithm: HS256 or EdDSA")
+	fs.DurationVar(&c.Auth.AccessTTL, "access-ttl", c.Auth.AccessTTL, "lifetime of access tokens")
+	fs.DurationVar(&c.Auth.RefreshTTL, "refresh-ttl", c.Auth.RefreshTTL, "lifetime of refresh tokens")
+	fs.DurationVar(&c.Auth.ResetTTL, "reset-ttl", c.Auth.ResetTTL, "lifetime of password reset tokens")
+	fs.StringVar(&c.Auth.Notifier, "notifier", c.Auth.Notifier, "password reset notifier: log or file")
+	fs.StringVar(&c.Auth.NotifierFile, "notifier-file", c.Auth.NotifierFile, "file that the file notifier writes into")
+	fs.BoolVar(&c.Auth.RequireStaffMFA, "require-staff-mfa", c.Auth.RequireStaffMFA, "admins and fleet managers must pass two factor authentication")
+
+	fs.IntVar(&c.Password.MinLength, "password-min-length", c.Password.MinLength, "minimum length of new passwords")
+	fs.BoolVar(&c.Password.RequireUpper, "password-upper", c.Password.RequireUpper, "new passwords must contain an upper case letter")
+	fs.BoolVar(&c.Password.RequireLower, "password-lower", c.Password.RequireLower, "new passwords must contain a lower case letter")
+	fs.BoolVar(&c.Password.RequireDigit, "password-digit", c.Password.RequireDigit, "new passwords must contain a digit")
+	fs.BoolVar(&c.Password.RequireSymbol, "password-symbol", c.Password.RequireSymbol, "new passwords must contain a symbol")
+	fs.StringVar(&c.Password.BreachedList, "breached-passwords", c.Password.BreachedList, "file with one breached password per line")
+	fs.StringVar(&c.Password.HashAlg, "password-hash", c.Password.HashAlg, "password hashing algorithm: bcrypt or argon2id")
+	fs.IntVar(&c.Password.BcryptCost, "bcrypt-cost", c.Password.BcryptCost, "bcrypt cost")
+	fs.UintVar(&c.Password.Argon2Time, "argon2-time", c.Password.Argon2Time, "argon2id iterations")
+	fs.UintVar(&c.Password.Argon2Memory, "argon2-memory", c.Password.Argon2Memory, "argon2id memory in KiB")
+	fs.UintVar(&c.Password.Argon2Threads, "argon2-threads", c.Password.Argon2Threads, "argon2id parallelism")
+
+	fs.IntVar(&c.Lockout.FreeAttempts, "lockout-free-attempts", c.Lockout.FreeAttempts, "failed logins of a user before the backoff starts")
+	fs.IntVar(&c.Lockout.FreeIPAttempts, "lockout-free-ip-attempts", c.Lockout.FreeIPAttempts, "failed logins of a client ip before the backoff starts")
+	fs.DurationVar(&c.Lockout.BaseDelay, "lockout-base-delay", c.Lockout.BaseDelay, "first backoff delay; it doubles for every failure")
+	fs.DurationVar(&c.Lockout.MaxDelay, "lockout-max-delay", c.Lockout.MaxDelay, "maximum backoff delay")
+	fs.IntVar(&c.Lockout.MaxUserFailures, "lockout-user-failures", c.Lockout.MaxUserFailures, "failed logins that lock a user")
+	fs.IntVar(&c.Lockout.MaxIPFailures, "lockout-ip-failures", c.Lockout.MaxIPFailures, "failed logins that lock a client ip")
+	fs.DurationVar(&c.Lockout.LockoutDuration, "lockout-duration", c.Lockout.LockoutDuration, "how long a user or an ip is locked")
+	fs.DurationVar(&c.Lockout.FailureWindow, "lockout-window", c.Lockout.FailureWindow, "failed logins older than this are forgotten")
+
+	fs.StringVar(&c.DB.DSN, "db", c.DB.DSN, "postgres:// url, path of the sqlite database, "+StoreBolt+"path of a bbolt file or "+StoreMemory+" for an in memory backend")
+	fs.DurationVar(&c.DB.ReadTimeout, "db-read-timeout", c.DB.ReadTimeout, "timeout of database reads")
+	fs.DurationVar(&c.DB.WriteTimeout, "db-write-timeout", c.DB.WriteTimeout, "timeout of database writes")
+	fs.DurationVar(&c.DB.ListTimeout, "db-list-timeout", c.DB.ListTimeout, "timeout of database listings")
+	fs.StringVar(&c.DB.SQLiteJournalMode, "sqlite-journal-mode", c.DB.SQLiteJournalMode, "sqlite journal mode: WAL, DELETE, TRUNCATE, PERSIST, MEMORY or OFF")
+	fs.StringVar(&c.DB.SQLiteSynchronous, "sqlite-synchronous", c.DB.SQLiteSynchronous, "sqlite synchronous level: OFF, NORMAL, FULL or EXTRA")
+	fs.DurationVar(&c.DB.SQLiteBusyTimeout, "sqlite-busy-timeout", c.DB.SQLiteBusyTimeout, "how long sqlite waits for the lock of another connection")
+	fs.BoolVar(&c.DB.SQLiteForeignKeys, "sqlite-foreign-keys", c.DB.SQLiteForeignKeys, "enforce the foreign keys and ON DELETE CASCADE of sqlite")
+	fs.IntVar(&c.DB.SQLiteCacheSize, "sqlite-cache-size", c.DB.SQLiteCacheSize, "sqlite page cache of every connection; pages when positive, KiB when negative")
+	fs.IntVar(&c.DB.MaxOpenConns, "db-max-open-conns", c.DB.MaxOpenConns, "maximum open database connections; 0 means no limit")
+	fs.IntVar(&c.DB.MaxIdleConns, "db-max-idle-conns", c.DB.MaxIdleConns, "database connections that are kept open when idle")
+
+	fs.StringVar(&c.Backup.Dir, "backup-dir", c.Backup.Dir, "directory of the sqlite snapshots")
+	fs.BoolVar(&c.Backup.Gzip, "backup-gzip", c.Backup.Gzip, "compress new snapshots by gzip")
+	fs.IntVar(&c.Backup.Keep, "backup-keep", c.Backup.Keep, "number of the newest snapshots that are kept; 0 keeps all")
+	fs.DurationVar(&c.Backup.MaxAge, "backup-max-age", c.Backup.MaxAge, "snapshots older than this are removed; 0 keeps them")
+}
+
+// DriverOptions use for getting the repo.DriverOptions of the DBConfig
+func (c *DBConfig) DriverOptions() repo.DriverOptions {
+	return repo.DriverOptions{
+		JournalMode:  c.SQLiteJournalMode,
+		Synchronous:  c.SQLiteSynchronous,
+		BusyTimeout:  c.SQLiteBusyTimeout,
+		ForeignKeys:  c.SQLiteForeignKeys,
+		CacheSize:    c.SQLiteCacheSize,
+		MaxOpenConns: c.MaxOpenConns,
+		MaxIdleConns: c.MaxIdleConns,
+	}
+}
+
+// Argon2idParams use for getting the argon2id parameters of the PasswordConfig
+func (p *PasswordConfig) Argon2idParams() passwords.Argon2idParams {
+	return passwords.Argon2idParams{
+		Time:    uint32(p.Argon2Time),
+		Memory:  uint32(p.Argon2Memory),
+		Threads: uint8(p.Argon2Threads),
+		SaltLen: 16,
+		KeyLen:  32,
+	}
+}
+
+// Validate use for checking the Config values are usable
+func (c *Config) Validate() error {
+	switch c.Auth.Mode {
+	case AuthModeSession, AuthModeToken, AuthModeBoth:
+	default:
+		return errors.New("auth-mode must be session, token or both")
+	}
+
+	switch c.Auth.TokenAlg {
+	case TokenAlgHS256, TokenAlgEdDSA:
+	default:
+		return errors.New("token-alg must be HS256 or EdDSA")
+	}
+
+	if c.Auth.AccessTTL <= 0 || c.Auth.RefreshTTL <= 0 || c.Auth.ResetTTL <= 0 {
+		return errors.New("access-ttl, refresh-ttl and reset-ttl must be positive")
+	}
+
+	switch c.Auth.Notifier {
+	case NotifierLog, NotifierFile:
+	default:
+		return errors.New("notifier must be log or file")
+	}
+
+	if c.Password.MinLength < 1 {
+		return errors.New("password-min-length must be positive")
+	}
+
+	switch c.Password.HashAlg {
+	case passwords.AlgBcrypt, passwords.AlgArgon2id:
+	default:
+		return errors.New("password-hash must be bcrypt or argon2id")
+	}
+
+	if c.Password.BcryptCost < bcrypt.MinCost || c.Password.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	if c.Password.Argon2Time < 1 || c.Password.Argon2Memory < 8*c.Password.Argon2Threads ||
+		c.Password.Argon2Threads < 1 || c.Password.Argon2Threads > 255 {
+		return errors.New("argon2-time must be positive, argon2-threads between 1 and 255 and argon2-memory at least 8 KiB per thread")
+	}
+
+	if c.Lockout.MaxUserFailures < 1 || c.Lockout.MaxIPFailures < 1 {
+		return errors.New("lockout-user-failures and lockout-ip-failures must be positive")
+	}
+
+	if c.DB.DSN == "" || c.DB.DSN == StoreBolt {
+		return errors.New("db must be a postgres:// url, a path, " + StoreBolt + "path or " + StoreMemory)
+	}
+
+	if c.DB.ReadTimeout <= 0 || c.DB.WriteTimeout <= 0 || c.DB.ListTimeout <= 0 {
+		return errors.New("db-read-timeout, db-write-timeout and db-list-timeout must be positive")
+	}
+
+	err := c.DB.DriverOptions().Validate()
+	if err != nil {
+		return err
+	}
+
+	if c.Backup.Dir == "" || c.Backup.Keep < 0 || c.Backup.MaxAge < 0 {
+		return errors.New("backup-dir is required and backup-keep and backup-max-age can not be negative")
+	}
+
+	return nil
+}
+
+// SessionsEnabled use for checking the session cookie is accepted
+func (a *AuthConfig) SessionsEnabled() bool {
+	return a.Mode == AuthModeSession || a.Mode == AuthModeBoth
+}
+
+// TokensEnabled use for checking the access tokens are accepted
+func (a *AuthConfig) TokensEnabled() bool {
+	return a.Mode == AuthModeToken || a.Mode == AuthModeBoth
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	zerolog "github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApiKeyPrefix is the prefix of every issued api key for recognizing them in logs and configs
+const ApiKeyPrefix = "ucs_"
+
+// ActionManageApiKeys is the action for issuing and revoking api keys
+const ActionManageApiKeys = "manage_api_keys"
+
+// generateApiKey use for creating a random api key with its sha256 hash
+func generateApiKey() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := ApiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	return key, hashToken(key), nil
+}
+
+// hashToken api keys and refresh tokens have enough entropy; so a fast hash is enough and keeps the lookup per request cheap
+func hashToken(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateApiKey use for finding the principal of a bearer api key
+func (ac *ApiConfig) authenticateApiKey(ctx context.Context, key string) (*Principal, error) {
+	apiKey, err := ac.DHolder.GetApiKeyByHash(ctx, hashToken(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.RevokedAt != nil {
+		return nil, errors.New("api key is revoked")
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		return nil, errors.New("api key is expired")
+	}
+
+	role, err := ac.DHolder.GetUserRole(ctx, apiKey.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ac.DHolder.TouchApiKey(ctx, apiKey.ID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+	}
+
+	return &Principal{
+		UserID:   apiKey.UserID,
+		Role:     role,
+		Scopes:   apiKey.Scopes,
+		ApiKeyID: apiKey.ID,
+	}, nil
+}
+
+// AddApiKeyHandler use for issuing an api key for the logged in user
+func (ac *ApiConfig) AddApiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	// api keys can not issue other api keys
+	p := PrincipalFromContext(r.Context())
+	if p == nil || p.ApiKeyID != 0 {
+		forbidden(w, p, ActionManageApiKeys)
+		return
+	}
+
+	var keyReq *models.ApiKeyRequest = &models.ApiKeyRequest{}
+	err := json.NewDecoder(r.Body).Decode(keyReq)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	if strings.TrimSpace(keyReq.Name) == "" {
+		invalidField(w, "name", "name is empty, fill it")
+		return
+	}
+	for _, scope := range keyReq.Scopes {
+		if scope != models.ScopeUsersWrite && scope != models.ScopeCarsWrite {
+			invalidField(w, "scopes", scope+" is not a valid scope")
+			return
+		}
+	}
+
+	key, keyHash, err := generateApiKey()
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	apiKey := &models.ApiKey{
+		UserID:    p.UserID,
+		Name:      keyReq.Name,
+		Prefix:    key[:len(ApiKeyPrefix)+8],
+		Scopes:    keyReq.Scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if apiKey.Scopes == nil {
+		apiKey.Scopes = []string{}
+	}
+	if keyReq.ExpiresInDays > 0 {
+		expires := apiKey.CreatedAt.AddDate(0, 0, keyReq.ExpiresInDays)
+		apiKey.ExpiresAt = &expires
+	}
+
+	err = ac.DHolder.AddApiKey(r.Context(), apiKey, keyHash)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = dResponseWriter(w, &models.IssuedApiKey{Key: key, ApiKey: apiKey}, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// GetApiKeysHandler use for listing the api keys of the logged in user
+func (ac *ApiConfig) GetApiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || p.ApiKeyID != 0 {
+		forbidden(w, p, ActionManageApiKeys)
+		return
+	}
+
+	keys, err := ac.DHolder.GetApiKeysByUser(r.Context(), p.UserID)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = dResponseWriter(w, keys, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// RevokeApiKeyHandler use for revoking an api key of the logged in user
+func (ac *ApiConfig) RevokeApiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || p.ApiKeyID != 0 {
+		forbidden(w, p, ActionManageApiKeys)
+		return
+	}
+
+	keyID, err := strconv.Atoi(r.URL.Query().Get("key_id"))
+	if err != nil {
+		invalidParam(w, "key_id", "key_id is not an integer")
+		return
+	}
+
+	err = ac.DHolder.RevokeApiKey(r.Context(), keyID, p.UserID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		failed(w, err)
+		return
+	}
+
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "Api Key Revoked",
+	}
+
+	err = dResponseWriter(w, stat, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/passwords"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	zerolog "github.com/rs/zerolog/log"
+	"net/http"
+	"time"
+)
+
+// SessionUserKey is the key that the authenticated user id stored under it in the session
+const SessionUserKey = "auth_user_id"
+
+// checkCredentials use for comparing the password of a login payload with the stored hash;
+// the comparison is skipped while the user or the client ip is backing off or locked
+// and an outdated hash is replaced by a hash of the current algorithm and parameters.
+// An unknown user and a wrong password both return passwords.ErrMismatch and are counted as failures;
+// the errors of the repository are returned as they are and are not counted
+func (ac *ApiConfig) checkCredentials(r *http.Request, cred *models.Credentials) error {
+	err := ac.checkLockout(r, cred.UserID)
+	if err != nil {
+		return err
+	}
+
+	hashedPass, err := ac.DHolder.GetUserPassword(r.Context(), cred.UserID)
+	if errors.Is(err, repo.ErrNotFound) {
+		// the dummy hash takes as long to compare as a stored one, so the time of the answer does not
+		// tell which users exist
+		_, _ = ac.Hasher.Verify(ac.dummyPasswordHash(), cred.Password)
+		ac.recordAuthFailure(r, cred.UserID)
+		return passwords.ErrMismatch
+	} else if err != nil {
+		return err
+	}
+
+	rehash, err := ac.Hasher.Verify(hashedPass, cred.Password)
+	if errors.Is(err, passwords.ErrMismatch) {
+		ac.recordAuthFailure(r, cred.UserID)
+		return err
+	} else if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	if rehash {
+		ac.rehashPassword(r.Context(), cred.UserID, cred.Password)
+	}
+
+	return nil
+}
+
+// dummyPasswordHash use for getting the hash of a random password by the preferred algorithm of the Hasher
+func (ac *ApiConfig) dummyPasswordHash() string {
+	ac.dummyOnce.Do(func() {
+		b := make([]byte, 32)
+		_, err := rand.Read(b)
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+			return
+		}
+
+		ac.dummyHash, err = ac.Hasher.Hash(base64.RawURLEncoding.EncodeToString(b))
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+		}
+	})
+
+	return ac.dummyHash
+}
+
+// credentialsFailed use for writing the response of a failed checkCredentials; a wrong password or an unknown
+// user is answered by 401, a lockout by 429 and the other errors by failed
+func credentialsFailed(w http.ResponseWriter, err error, message string) {
+	var locked *lockedOutError
+	if !errors.Is(err, passwords.ErrMismatch) && !errors.As(err, &locked) {
+		failed(w, err)
+		return
+	}
+
+	authFailed(w, err, message)
+}
+
+// rehashPassword use for upgrading the stored hash of a user; a failure keeps the old hash working
+func (ac *ApiConfig) rehashPassword(ctx context.Context, userID int, password string) {
+	hashedPass, err := ac.Hasher.Hash(password)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	err = ac.DHolder.UpdateUserPassword(ctx, userID, hashedPass)
+	if err != nil {
+		return
+	}
+
+	zerolog.Info().Msg(fmt.Sprintf("password hash of user %d is upgraded", userID))
+}
+
+// LoginHandler use for checking user credentials and storing the user in the session
+func (ac *ApiConfig) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var cred *models.Credentials = &models.Credentials{}
+	err := json.NewDecoder(r.Body).Decode(cred)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	err = ac.checkCredentials(r, cred)
+	if err != nil {
+		credentialsFailed(w, err, "invalid credentials")
+		return
+	}
+
+	mfaEnabled, err := ac.twoFactorEnabled(r.Context(), cred.UserID)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	// renewing the token prevents session fixation attacks
+	err = ac.ScsManager.RenewToken(r.Context())
+	if err != nil {
+		failed(w, err)
+		return
+	}
+	// the renewed token keeps the data of the session, so a former login must not leave its user or mfa in it
+	ac.clearLogin(r.Context())
+
+	status := &models.LoginStatus{
+		Ok:      true,
+		Message: "Logged In",
+	}
+	if mfaEnabled {
+		// the session is only elevated to a logged in user by LoginTwoFactorHandler
+		ac.ScsManager.Put(r.Context(), SessionMFAPendingKey, cred.UserID)
+		ac.ScsManager.Put(r.Context(), SessionMFAPendingAtKey, int(time.Now().Unix()))
+		status.Message = "Two Factor Code Required"
+		status.TwoFactorRequired = true
+	} else {
+		ac.ScsManager.Put(r.Context(), SessionUserKey, cred.UserID)
+		ac.clearAuthFailures(r.Context(), cred.UserID)
+	}
+
+	err = dResponseWriter(w, status, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// clearLogin use for removing the logged in user, its two factor state and a pending second login step
+// from the session
+func (ac *ApiConfig) clearLogin(ctx context.Context) {
+	ac.ScsManager.Remove(ctx, SessionUserKey)
+	ac.ScsManager.Remove(ctx, SessionMFAKey)
+	ac.ScsManager.Remove(ctx, SessionMFAPendingKey)
+	ac.ScsManager.Remove(ctx, SessionMFAPendingAtKey)
+}
+
+// sessionLister is a scs.Store that can list the data of its sessions by their tokens
+type sessionLister interface {
+	All() (map[string][]byte, error)
+}
+
+// destroyUserSessions use for removing every session that the user is logged in or is logging in by;
+// a store that can not list its sessions is left as it is
+func (ac *ApiConfig) destroyUserSessions(userID int) error {
+	store, ok := ac.ScsManager.Store.(sessionLister)
+	if !ok {
+		return nil
+	}
+
+	sessions, err := store.All()
+	if err != nil {
+		return err
+	}
+	for token, data := range sessions {
+		_, values, err := ac.ScsManager.Codec.Decode(data)
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+			continue
+		}
+		if values[SessionUserKey] != userID && values[SessionMFAPendingKey] != userID {
+			continue
+		}
+
+		err = ac.ScsManager.Store.Delete(token)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LogoutHandler use for destroying the session of the current user
+func (ac *ApiConfig) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	ac.clearLogin(r.Context())
+	err := ac.ScsManager.Destroy(r.Context())
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "Logged Out",
+	}
+
+	err = dResponseWriter(w, stat, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// MeHandler use for getting the current logged in user with its cars
+func (ac *ApiConfig) MeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil {
+		unauthorized(w, "you are not logged in")
+		return
+	}
+
+	user, err := ac.DHolder.GetUserByID(r.Context(), p.UserID)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = dResponseWriter(w, models.NewUserView(user), http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/DapperBlondie/users-cars-systems/src/config"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/passwords"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/memory"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+const testPassword = "longsecret42"
+
+// timeoutStore is a memory.Store whose password lookups time out
+type timeoutStore struct {
+	*memory.Store
+}
+
+func (s *timeoutStore) GetUserPassword(ctx context.Context, userID int) (string, error) {
+	return "", context.DeadlineExceeded
+}
+
+func newAuthTestConfig(t *testing.T, store repo.ApiOpsInterface) *ApiConfig {
+	hasher, err := passwords.NewHasher(passwords.AlgBcrypt, bcrypt.MinCost, config.New().Password.Argon2idParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ApiConfig{DHolder: store, Lockout: config.New().Lockout, Hasher: hasher}
+}
+
+// failures use for getting the failures that are recorded for the user and for the ip of r
+func failures(t *testing.T, store repo.ApiOpsInterface, r *http.Request, userID int) (int, int) {
+	var counts [2]int
+	for i, key := range [][2]string{{models.FailureKindUser, strconv.Itoa(userID)}, {models.FailureKindIP, clientIP(r)}} {
+		f, err := store.GetAuthFailure(context.Background(), key[0], key[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f != nil {
+			counts[i] = f.Failures
+		}
+	}
+
+	return counts[0], counts[1]
+}
+
+func TestCheckCredentials(t *testing.T) {
+	store := memory.NewStore()
+	ac := newAuthTestConfig(t, store)
+	hash, err := ac.Hasher.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.Users{CompleteName: "Ann Lee", BirthDay: "1990-03-04", Password: hash, Role: models.RoleOwner}
+	err = store.AddUser(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		userID   int
+		password string
+		err      error
+		failures int
+	}{
+		{"the password", user.ID, testPassword, nil, 0},
+		{"a wrong password", user.ID, "wrong-secret-42", passwords.ErrMismatch, 1},
+		{"an unknown user", user.ID + 100, testPassword, passwords.ErrMismatch, 1},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = "192.0.2." + strconv.Itoa(c.userID) + ":1234"
+
+		err := ac.checkCredentials(r, &models.Credentials{UserID: c.userID, Password: c.password})
+		if !errors.Is(err, c.err) || (c.err == nil && err != nil) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.err)
+		}
+
+		userFailures, ipFailures := failures(t, store, r, c.userID)
+		if userFailures != c.failures || ipFailures != c.failures {
+			t.Errorf("%s: recorded %d failures of the user and %d of the ip, want %d", c.name, userFailures, ipFailures, c.failures)
+		}
+	}
+
+	// the unknown user is compared with a hash of the preferred algorithm and cost
+	cost, err := bcrypt.Cost([]byte(ac.dummyHash))
+	if err != nil || cost != bcrypt.MinCost {
+		t.Errorf("the dummy hash %q is not a bcrypt hash of the cost %d", ac.dummyHash, bcrypt.MinCost)
+	}
+}
+
+func TestCheckCredentialsDoesNotCountRepositoryErrors(t *testing.T) {
+	store := &timeoutStore{Store: memory.NewStore()}
+	ac := newAuthTestConfig(t, store)
+
+	r := httptest.NewRequest(http.MethodPost, "/login", nil)
+	err := ac.checkCredentials(r, &models.Credentials{UserID: 1, Password: testPassword})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	userFailures, ipFailures := failures(t, store, r, 1)
+	if userFailures != 0 || ipFailures != 0 {
+		t.Errorf("a timeout recorded %d failures of the user and %d of the ip", userFailures, ipFailures)
+	}
+
+	w := httptest.NewRecorder()
+	credentialsFailed(w, err, "invalid credentials")
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("a timeout is answered by %d", w.Code)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	zerolog "github.com/rs/zerolog/log"
+	"net/http"
+)
+
+// ActionManageBackups is the action of taking and listing the snapshots of the database
+const ActionManageBackups = "manage_backups"
+
+// BackupHandler use for taking a snapshot of the sqlite database while the api runs; only admins can use it
+func (ac *ApiConfig) BackupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.IsAdmin() {
+		forbidden(w, p, ActionManageBackups)
+		return
+	}
+
+	if ac.Backups == nil {
+		problem(w, http.StatusNotImplemented, CodeNotImplemented, "", "backups are only available for the sqlite backend")
+		return
+	}
+
+	snap, err := ac.Backups.Backup(r.Context())
+	if err != nil {
+		failed(w, err)
+		return
+	}
+	zerolog.Info().Msg("snapshot " + snap.File + " is taken by an admin")
+
+	err = dResponseWriter(w, snap, http.StatusCreated)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// GetBackupsHandler use for listing the snapshots from the oldest to the newest; only admins can use it
+func (ac *ApiConfig) GetBackupsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.IsAdmin() {
+		forbidden(w, p, ActionManageBackups)
+		return
+	}
+
+	if ac.Backups == nil {
+		problem(w, http.StatusNotImplemented, CodeNotImplemented, "", "backups are only available for the sqlite backend")
+		return
+	}
+
+	snaps, err := ac.Backups.Snapshots()
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = dResponseWriter(w, snaps, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
@@ -3,76 +3,74 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"github.com/DapperBlondie/users-cars-systems/src/backup"
+	"github.com/DapperBlondie/users-cars-systems/src/config"
 	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/notify"
+	"github.com/DapperBlondie/users-cars-systems/src/passwords"
 	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"github.com/DapperBlondie/users-cars-systems/src/tokens"
+	"github.com/DapperBlondie/users-cars-systems/src/validate"
 	"github.com/alexedwards/scs/v2"
-	"github.com/go-chi/chi"
 	zerolog "github.com/rs/zerolog/log"
-	"golang.org/x/crypto/bcrypt"
 	"net/http"
 	"reflect"
 	"strconv"
+	"sync"
 )
 
 type ApiConfig struct {
 	ScsManager *scs.SessionManager
-	DHolder    *repo.DBHolder
+	DHolder    repo.ApiOpsInterface
+	Auth       *config.AuthConfig
+	Lockout    *config.LockoutConfig
+	Tokens     *tokens.Manager
+	Notifier   notify.Notifier
+	Policy     *passwords.Policy
+	Hasher     *passwords.Hasher
+	// Backups is nil when the backend is not sqlite
+	Backups *backup.Manager
+
+	// dummyHash is compared with the passwords of the users that do not exist; dummyOnce hashes it once
+	dummyHash string
+	dummyOnce sync.Once
 }
 
 var ApiConf *ApiConfig
 
-func NewApiConf(scs *scs.SessionManager, dh *repo.DBHolder) {
+func NewApiConf(scs *scs.SessionManager, dh repo.ApiOpsInterface, auth *config.AuthConfig, lockout *config.LockoutConfig, tm *tokens.Manager, nt notify.Notifier, policy *passwords.Policy, hasher *passwords.Hasher, backups *backup.Manager) {
 	ApiConf = &ApiConfig{
 		ScsManager: scs,
 		DHolder:    dh,
+		Auth:       auth,
+		Lockout:    lockout,
+		Tokens:     tm,
+		Notifier:   nt,
+		Policy:     policy,
+		Hasher:     hasher,
+		Backups:    backups,
 	}
 }
 
-// dResponseWriter use for writing response to the user
+// dResponseWriter use for writing response to the user; the body is encoded before the status is written,
+// so a body that can not be encoded is answered by a 500 problem instead of a broken success
 func dResponseWriter(w http.ResponseWriter, data interface{}, HStat int) error {
 	dataType := reflect.TypeOf(data)
 	if dataType.Kind() == reflect.String {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(HStat)
-		w.Header().Set("Content-Type", "application/text")
 
 		_, err := w.Write([]byte(data.(string)))
 		return err
-	} else if reflect.PtrTo(dataType).Kind() == dataType.Kind() {
-		w.WriteHeader(HStat)
-		w.Header().Set("Content-Type", "application/json")
-
+	} else if dataType.Kind() == reflect.Ptr || dataType.Kind() == reflect.Struct || dataType.Kind() == reflect.Slice {
 		outData, err := json.MarshalIndent(data, "", "\t")
 		if err != nil {
-			zerolog.Error().Msg(err.Error())
-			w.Write([]byte(err.Error()))
+			failed(w, err)
 			return err
 		}
 
-		_, err = w.Write(outData)
-		return err
-	} else if reflect.Struct == dataType.Kind() {
-		w.WriteHeader(HStat)
 		w.Header().Set("Content-Type", "application/json")
-
-		outData, err := json.MarshalIndent(data, "", "\t")
-		if err != nil {
-			zerolog.Error().Msg(err.Error())
-			w.Write([]byte(err.Error()))
-			return err
-		}
-
-		_, err = w.Write(outData)
-		return err
-	} else if reflect.Slice == dataType.Kind() {
 		w.WriteHeader(HStat)
-		w.Header().Set("Content-Type", "application/json")
-
-		outData, err := json.MarshalIndent(data, "", "\t")
-		if err != nil {
-			zerolog.Error().Msg(err.Error())
-			w.Write([]byte(err.Error()))
-			return err
-		}
 
 		_, err = w.Write(outData)
 		return err
@@ -84,8 +82,7 @@ func dResponseWriter(w http.ResponseWriter, data interface{}, HStat int) error {
 // CheckStatus just for showing the status of app
 func (ac *ApiConfig) CheckStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, r.Method+" is not available", http.StatusInternalServerError)
-		zerolog.Error().Msg(r.Method + " is not available")
+		methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
@@ -106,69 +103,94 @@ func (ac *ApiConfig) CheckStatus(w http.ResponseWriter, r *http.Request) {
 // AddUserHandler use for adding users into the db
 func (ac *ApiConfig) AddUserHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, r.Method+" is not available", http.StatusInternalServerError)
-		zerolog.Error().Msg(r.Method + " is not available")
+		methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
-	var user *models.Users
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	_, ok := ac.addUser(w, r)
+	if !ok {
 		return
 	}
 
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(user.Password), 12)
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "User Added",
+	}
+
+	err := dResponseWriter(w, stat, http.StatusOK)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	user.Password = string(hashedPass)
 
-	err = ac.DHolder.AddUser(user)
+	return
+}
+
+// addUser use for adding the user of the body with its hashed password; it writes the error when it fails
+func (ac *ApiConfig) addUser(w http.ResponseWriter, r *http.Request) (*models.Users, bool) {
+	var body *models.UserCreate
+	err := json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		invalidBody(w, err)
+		return nil, false
+	}
+	if body == nil {
+		problem(w, http.StatusBadRequest, CodeInvalidBody, "", "body is empty")
+		return nil, false
 	}
 
-	stat := &models.StatusIdentifier{
-		Ok:      true,
-		Message: "User Added",
+	errs := append(validate.Struct(body), ac.passwordErrors("password", body.Password)...)
+	if len(errs) > 0 {
+		invalidFields(w, errs)
+		return nil, false
 	}
 
-	err = dResponseWriter(w, stat, http.StatusOK)
+	hashedPass, err := ac.Hasher.Hash(body.Password)
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		return
+		failed(w, err)
+		return nil, false
 	}
+	// roles can only be granted by admins through SetRoleHandler, so the new user is an owner
+	user := body.User()
+	user.Password = hashedPass
 
-	return
+	err = ac.DHolder.AddUser(r.Context(), user)
+	if err != nil {
+		failed(w, err)
+		return nil, false
+	}
+
+	return user, true
 }
 
 // DeleteUserHandler use for deleting users from database
 func (ac *ApiConfig) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, r.Method+" is not available", http.StatusInternalServerError)
-		zerolog.Error().Msg(r.Method + " is not available")
+		methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
-		http.Error(w, "user_id is empty, fill it ", http.StatusInternalServerError)
+		invalidParam(w, "user_id", "user_id is empty, fill it")
 		return
 	}
 
 	id, err := strconv.Atoi(userID)
 	if err != nil {
-		http.Error(w, "user_id is not an integer", http.StatusInternalServerError)
+		invalidParam(w, "user_id", "user_id is not an integer")
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageUser(id) {
+		forbidden(w, p, ActionDeleteUser)
 		return
 	}
 
-	err = ac.DHolder.DeleteUser(id)
+	err = ac.DHolder.DeleteUser(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		failed(w, err)
 		return
 	}
 
@@ -189,23 +211,31 @@ func (ac *ApiConfig) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 // AddCarHandler use for adding cars associated with user into db
 func (ac *ApiConfig) AddCarHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, r.Method+" is not available", http.StatusInternalServerError)
-		zerolog.Error().Msg(r.Method + " is not available")
+		methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
-	var car *models.Cars
-	err := json.NewDecoder(r.Body).Decode(&car)
+	var body *models.CarCreate = &models.CarCreate{}
+	err := json.NewDecoder(r.Body).Decode(body)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		invalidBody(w, err)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageCar(body.OwnerID) {
+		forbidden(w, p, ActionAddCar)
+		return
+	}
+
+	if !valid(w, body) {
 		return
 	}
 
-	err = ac.DHolder.AddCar(car)
+	err = ac.DHolder.AddCar(r.Context(), body.Car(body.OwnerID))
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		failed(w, err)
 		return
 	}
 
@@ -226,30 +256,24 @@ func (ac *ApiConfig) AddCarHandler(w http.ResponseWriter, r *http.Request) {
 // GetUserHandler use for get a user by its ID with associated cars
 func (ac *ApiConfig) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, r.Method+" is not available", http.StatusInternalServerError)
-		zerolog.Error().Msg(r.Method + " is not available")
+		methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
-	userID := chi.URLParamFromCtx(r.Context(), "user_id")
-	id, err := strconv.Atoi(userID)
-	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	id, ok := pathID(w, r, "user_id")
+	if !ok {
 		return
 	}
 
-	user, err := ac.DHolder.GetUserByID(id)
+	user, err := ac.DHolder.GetUserByID(r.Context(), id)
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		failed(w, err)
 		return
 	}
 
-	err = dResponseWriter(w, user, http.StatusOK)
+	err = dResponseWriter(w, models.NewUserView(user), http.StatusOK)
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		failed(w, err)
 		return
 	}
 
@@ -263,26 +287,23 @@ func (ac *ApiConfig) GetAllUsersHandler(w http.ResponseWriter, r *http.Request)
 
 	limit, err := strconv.Atoi(lmt)
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		invalidParam(w, "limit", "limit is not an integer")
 		return
 	}
 
 	offset, err := strconv.Atoi(off)
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		invalidParam(w, "offset", "offset is not an integer")
 		return
 	}
 
-	users, err := ac.DHolder.GetAllUsers(limit, offset)
+	users, err := ac.DHolder.GetAllUsers(r.Context(), limit, offset)
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		failed(w, err)
 		return
 	}
 
-	err = dResponseWriter(w, users, http.StatusOK)
+	err = dResponseWriter(w, models.NewUserViews(users), http.StatusOK)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return
@@ -294,36 +315,37 @@ func (ac *ApiConfig) GetAllUsersHandler(w http.ResponseWriter, r *http.Request)
 // UpdateUserHandler use for updating a user its ID
 func (ac *ApiConfig) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, r.Method+" is not available", http.StatusInternalServerError)
-		zerolog.Error().Msg(r.Method + " is not available")
+		methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
-	var user *models.Users = &models.Users{}
-	err := json.NewDecoder(r.Body).Decode(user)
+	var body *models.UserUpdate = &models.UserUpdate{}
+	err := json.NewDecoder(r.Body).Decode(body)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		invalidBody(w, err)
 		return
 	}
 
-	sPass, err := bcrypt.GenerateFromPassword([]byte(user.Password), 12)
-	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageUser(body.ID) {
+		forbidden(w, p, ActionUpdateUser)
+		return
+	}
+
+	if !valid(w, body) {
 		return
 	}
-	user.Password = string(sPass)
 
-	err = ac.DHolder.UpdateUser(user)
+	// passwords only change through ChangePasswordHandler
+	err = ac.DHolder.UpdateUser(r.Context(), body.User(body.ID))
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		failed(w, err)
 		return
 	}
 
 	stat := &models.StatusIdentifier{
 		Ok:      true,
-		Message: "User Deleted",
+		Message: "User Updated",
 	}
 
 	err = dResponseWriter(w, stat, http.StatusOK)
@@ -338,27 +360,42 @@ func (ac *ApiConfig) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 // UpdateCarHandler use for updating a car by its id
 func (ac *ApiConfig) UpdateCarHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, r.Method+" is not available", http.StatusInternalServerError)
-		zerolog.Error().Msg(r.Method + " is not available")
+		methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
-	var car *models.Cars = &models.Cars{}
-	err := json.NewDecoder(r.Body).Decode(car)
+	var body *models.CarUpdate = &models.CarUpdate{}
+	err := json.NewDecoder(r.Body).Decode(body)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		invalidBody(w, err)
 		return
 	}
 
-	err = ac.DHolder.UpdateCar(car)
+	stored, err := ac.DHolder.GetCarByID(r.Context(), body.ID)
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
+		failed(w, err)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageCar(stored.OwnerID) {
+		forbidden(w, p, ActionUpdateCar)
+		return
+	}
+
+	if !valid(w, body) {
+		return
+	}
+
+	err = ac.DHolder.UpdateCar(r.Context(), body.Car(body.ID, stored.OwnerID))
+	if err != nil {
+		failed(w, err)
 		return
 	}
 
 	stat := &models.StatusIdentifier{
 		Ok:      true,
-		Message: "User Deleted",
+		Message: "Car Updated",
 	}
 
 	err = dResponseWriter(w, stat, http.StatusOK)
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	zerolog "github.com/rs/zerolog/log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ActionManageLockouts is the action of listing and removing the lockouts
+const ActionManageLockouts = "manage_lockouts"
+
+// lockedOutError returned instead of checking a password or a code while the user or the ip has to wait
+type lockedOutError struct {
+	wait time.Duration
+}
+
+func (e *lockedOutError) Error() string {
+	return fmt.Sprintf("too many failed attempts; retry after %d seconds", retryAfterSeconds(e.wait))
+}
+
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
+
+// clientIP use for getting the ip of the client from the connection; forwarded headers can be forged
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// backoffDelay use for getting the delay after failures; it doubles for every failure after the free attempts
+func (ac *ApiConfig) backoffDelay(failures, free int) time.Duration {
+	if failures < free {
+		return 0
+	}
+
+	delay := ac.Lockout.BaseDelay
+	for i := free; i < failures && delay < ac.Lockout.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > ac.Lockout.MaxDelay {
+		delay = ac.Lockout.MaxDelay
+	}
+
+	return delay
+}
+
+// waitFor use for getting how long a subject has to wait before its next attempt; a lockout lasts until
+// its end even when it is longer than the failure window
+func (ac *ApiConfig) waitFor(f *models.AuthFailure, now time.Time) time.Duration {
+	if f == nil {
+		return 0
+	}
+
+	var wait time.Duration
+	if f.LockedAt(now) {
+		wait = f.LockedUntil.Sub(now)
+	}
+	if now.Sub(f.LastFailure) > ac.Lockout.FailureWindow {
+		return wait
+	}
+	free := ac.Lockout.FreeAttempts
+	if f.Kind == models.FailureKindIP {
+		free = ac.Lockout.FreeIPAttempts
+	}
+	if backoff := f.LastFailure.Add(ac.backoffDelay(f.Failures, free)).Sub(now); backoff > wait {
+		wait = backoff
+	}
+
+	return wait
+}
+
+// checkLockout use for rejecting an attempt before the expensive hash comparison when the user or the ip has to wait
+func (ac *ApiConfig) checkLockout(r *http.Request, userID int) error {
+	now := time.Now().UTC()
+
+	userFailure, err := ac.DHolder.GetAuthFailure(r.Context(), models.FailureKindUser, strconv.Itoa(userID))
+	if err != nil {
+		return err
+	}
+	ipFailure, err := ac.DHolder.GetAuthFailure(r.Context(), models.FailureKindIP, clientIP(r))
+	if err != nil {
+		return err
+	}
+
+	wait := ac.waitFor(userFailure, now)
+	if ipWait := ac.waitFor(ipFailure, now); ipWait > wait {
+		wait = ipWait
+	}
+	if wait > 0 {
+		return &lockedOutError{wait: wait}
+	}
+
+	return nil
+}
+
+// recordAuthFailure use for counting a failed attempt for the user and for the ip
+func (ac *ApiConfig) recordAuthFailure(r *http.Request, userID int) {
+	// a client that closes the connection must not cancel the counting of its failure
+	ctx := context.Background()
+	f, err := ac.DHolder.RecordAuthFailure(ctx, models.FailureKindUser, strconv.Itoa(userID),
+		ac.Lockout.MaxUserFailures, ac.Lockout.LockoutDuration, ac.Lockout.FailureWindow)
+	if err == nil && f.Failures == ac.Lockout.MaxUserFailures {
+		zerolog.Warn().Msg(fmt.Sprintf("user %d is locked out for %s", userID, ac.Lockout.LockoutDuration))
+	}
+
+	ip := clientIP(r)
+	f, err = ac.DHolder.RecordAuthFailure(ctx, models.FailureKindIP, ip,
+		ac.Lockout.MaxIPFailures, ac.Lockout.LockoutDuration, ac.Lockout.FailureWindow)
+	if err == nil && f.Failures == ac.Lockout.MaxIPFailures {
+		zerolog.Warn().Msg(fmt.Sprintf("ip %s is locked out for %s", ip, ac.Lockout.LockoutDuration))
+	}
+}
+
+// clearAuthFailures use for forgetting the failed attempts of a user after it authenticated completely
+func (ac *ApiConfig) clearAuthFailures(ctx context.Context, userID int) {
+	err := ac.DHolder.ResetAuthFailures(ctx, models.FailureKindUser, strconv.Itoa(userID))
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+	}
+}
+
+// authFailed use for writing the response of a failed password or code check
+func authFailed(w http.ResponseWriter, err error, message string) {
+	var locked *lockedOutError
+	if errors.As(err, &locked) {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(locked.wait)))
+		problem(w, http.StatusTooManyRequests, CodeLockedOut, "", locked.Error())
+		return
+	}
+
+	unauthorized(w, message)
+}
+
+// GetLockoutsHandler use for listing the users and ips that have failed attempts; only admins can use it
+func (ac *ApiConfig) GetLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.IsAdmin() {
+		forbidden(w, p, ActionManageLockouts)
+		return
+	}
+
+	failures, err := ac.DHolder.GetAuthFailures(r.Context())
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, f := range failures {
+		f.Locked = f.LockedAt(now)
+	}
+
+	err = dResponseWriter(w, failures, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// UnlockHandler use for removing the failed attempts and the lockout of a user or an ip; only admins can use it
+func (ac *ApiConfig) UnlockHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.IsAdmin() {
+		forbidden(w, p, ActionManageLockouts)
+		return
+	}
+
+	var unlockReq *models.UnlockRequest = &models.UnlockRequest{}
+	err := json.NewDecoder(r.Body).Decode(unlockReq)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+	if unlockReq.UserID == 0 && unlockReq.IP == "" {
+		invalidField(w, "user_id", "user_id or ip is required")
+		return
+	}
+
+	if unlockReq.UserID != 0 {
+		err = ac.DHolder.ResetAuthFailures(r.Context(), models.FailureKindUser, strconv.Itoa(unlockReq.UserID))
+		if err != nil {
+			failed(w, err)
+			return
+		}
+	}
+	if unlockReq.IP != "" {
+		err = ac.DHolder.ResetAuthFailures(r.Context(), models.FailureKindIP, unlockReq.IP)
+		if err != nil {
+			failed(w, err)
+			return
+		}
+	}
+
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "Unlocked",
+	}
+
+	err = dResponseWriter(w, stat, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
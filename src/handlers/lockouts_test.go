@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"github.com/DapperBlondie/users-cars-systems/src/config"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelayDoubles(t *testing.T) {
+	ac := &ApiConfig{Lockout: &config.LockoutConfig{BaseDelay: time.Second, MaxDelay: 10 * time.Second}}
+
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for failures, delay := range want {
+		got := ac.backoffDelay(failures, 3)
+		if got != delay {
+			t.Errorf("%d failures with 3 free attempts: got %s, want %s", failures, got, delay)
+		}
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	lockout := config.New().Lockout
+	ac := &ApiConfig{Lockout: lockout}
+	now := time.Now().UTC()
+	lockedUntil := now.Add(2 * lockout.FailureWindow)
+
+	cases := []struct {
+		name    string
+		failure *models.AuthFailure
+		want    time.Duration
+	}{
+		{"no failure", nil, 0},
+		{"free attempts", &models.AuthFailure{Kind: models.FailureKindUser, Failures: lockout.FreeAttempts - 1, LastFailure: now}, 0},
+		{"first delay", &models.AuthFailure{Kind: models.FailureKindUser, Failures: lockout.FreeAttempts, LastFailure: now}, lockout.BaseDelay},
+		{"doubled delay", &models.AuthFailure{Kind: models.FailureKindUser, Failures: lockout.FreeAttempts + 1, LastFailure: now}, 2 * lockout.BaseDelay},
+		{"delay that passed", &models.AuthFailure{Kind: models.FailureKindUser, Failures: lockout.FreeAttempts + 1,
+			LastFailure: now.Add(-3 * lockout.BaseDelay)}, 0},
+		{"free attempts of an ip", &models.AuthFailure{Kind: models.FailureKindIP, Failures: lockout.FreeAttempts + 1, LastFailure: now}, 0},
+		{"failures out of the window", &models.AuthFailure{Kind: models.FailureKindUser, Failures: lockout.FreeAttempts + 5,
+			LastFailure: now.Add(-lockout.FailureWindow - time.Second)}, 0},
+		{"lockout that outlasts the window", &models.AuthFailure{Kind: models.FailureKindUser, Failures: lockout.MaxUserFailures,
+			LastFailure: now.Add(-lockout.FailureWindow - time.Second), LockedUntil: &lockedUntil}, 2 * lockout.FailureWindow},
+	}
+
+	for _, c := range cases {
+		got := ac.waitFor(c.failure, now)
+		if got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
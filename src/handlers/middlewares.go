@@ -1,11 +1,145 @@
 package handlers
 
-import "net/http"
+import (
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LegacyDeprecatedAt is when the routes out of APIPrefix are deprecated and LegacySunset is when they are removed
+var (
+	LegacyDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	LegacySunset       = time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC)
+)
 
 func (ac *ApiConfig) EnableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireAuth use for guarding routes that only logged in users or api keys can reach
+func (ac *ApiConfig) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			bearer := strings.TrimPrefix(authHeader, "Bearer ")
+			if strings.HasPrefix(bearer, ApiKeyPrefix) {
+				p, err := ac.authenticateApiKey(r.Context(), bearer)
+				if err != nil {
+					unauthorized(w, "invalid api key")
+					return
+				}
+
+				w.Header().Add("Cache-Control", "no-store")
+				next.ServeHTTP(w, withPrincipal(r, p))
+				return
+			}
+
+			if !ac.Auth.TokensEnabled() {
+				unauthorized(w, "access tokens are not enabled")
+				return
+			}
+
+			claims, err := ac.Tokens.Parse(bearer)
+			if err != nil {
+				unauthorized(w, "invalid access token")
+				return
+			}
+
+			w.Header().Add("Cache-Control", "no-store")
+			next.ServeHTTP(w, withPrincipal(r, &Principal{UserID: claims.Subject, Role: models.Role(claims.Role), MFA: claims.MFA}))
+			return
+		}
+
+		if !ac.Auth.SessionsEnabled() {
+			unauthorized(w, "you are not logged in")
+			return
+		}
+
+		userID := ac.ScsManager.GetInt(r.Context(), SessionUserKey)
+		if userID == 0 {
+			unauthorized(w, "you are not logged in")
+			return
+		}
+
+		role, err := ac.DHolder.GetUserRole(r.Context(), userID)
+		if err != nil {
+			unauthorized(w, "you are not logged in")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+		mfa := ac.ScsManager.GetBool(r.Context(), SessionMFAKey)
+		next.ServeHTTP(w, withPrincipal(r, &Principal{UserID: userID, Role: role, MFA: mfa}))
+	})
+}
+
+// RequireScope use for guarding routes that api keys can only reach with the scope
+func (ac *ApiConfig) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := PrincipalFromContext(r.Context())
+			if p == nil || !p.HasScope(scope) {
+				forbidden(w, p, "scope:"+scope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSessionMode use for hiding the session endpoints when sessions are disabled
+func (ac *ApiConfig) RequireSessionMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ac.Auth.SessionsEnabled() {
+			NotFoundHandler(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireTokenMode use for hiding the token endpoints when tokens are disabled
+func (ac *ApiConfig) RequireTokenMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ac.Auth.TokensEnabled() {
+			NotFoundHandler(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireStaffMFA use for denying admins and fleet managers that did not pass two factor authentication;
+// api keys are for machine clients and they are not checked
+func (ac *ApiConfig) RequireStaffMFA(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := PrincipalFromContext(r.Context())
+		if ac.Auth.RequireStaffMFA && p != nil && p.IsStaff() && p.ApiKeyID == 0 && !p.MFA {
+			forbidden(w, p, ActionStaffMFA)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Deprecated use for marking the legacy routes by the Deprecation header of RFC 9745 and the Sunset header of
+// RFC 8594; the headers are on every response of them, even when the caller is not authenticated
+func (ac *ApiConfig) Deprecated(next http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(LegacyDeprecatedAt.Unix(), 10)
+	sunset := LegacySunset.UTC().Format(http.TimeFormat)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", deprecation)
+		w.Header().Set("Sunset", sunset)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/notify"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"github.com/DapperBlondie/users-cars-systems/src/validate"
+	"github.com/go-chi/chi"
+	zerolog "github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ActionChangePassword is the action for changing the password of a user
+const ActionChangePassword = "change_password"
+
+// passwordErrors use for checking a new password of the field against the policy
+func (ac *ApiConfig) passwordErrors(field, password string) validate.Errors {
+	violations := ac.Policy.Check(password)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return validate.Errors{{Field: field, Rule: "policy", Message: strings.Join(violations, "; ")}}
+}
+
+// checkPasswordPolicy use for rejecting a new password of the field that violates the policy
+func (ac *ApiConfig) checkPasswordPolicy(w http.ResponseWriter, field, password string) bool {
+	errs := ac.passwordErrors(field, password)
+	if errs == nil {
+		return true
+	}
+
+	invalidFields(w, errs)
+	return false
+}
+
+// ChangePasswordHandler use for changing the password of the logged in user by its current password
+func (ac *ApiConfig) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	userID, err := strconv.Atoi(chi.URLParamFromCtx(r.Context(), "user_id"))
+	if err != nil {
+		invalidParam(w, "user_id", "user_id is not an integer")
+		return
+	}
+
+	// even admins need the current password; so only users can change their own password
+	p := PrincipalFromContext(r.Context())
+	if p == nil || p.UserID != userID {
+		forbidden(w, p, ActionChangePassword)
+		return
+	}
+
+	var changeReq *models.ChangePasswordRequest = &models.ChangePasswordRequest{}
+	err = json.NewDecoder(r.Body).Decode(changeReq)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	err = ac.checkCredentials(r, &models.Credentials{UserID: userID, Password: changeReq.CurrentPassword})
+	if err != nil {
+		credentialsFailed(w, err, "current password is wrong")
+		return
+	}
+
+	if !ac.checkPasswordPolicy(w, "new_password", changeReq.NewPassword) {
+		return
+	}
+
+	hashedPass, err := ac.Hasher.Hash(changeReq.NewPassword)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = ac.DHolder.UpdateUserPassword(r.Context(), userID, hashedPass)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = ac.DHolder.RevokeUserRefreshTokens(r.Context(), userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+	}
+	if ac.Auth.SessionsEnabled() && p.ApiKeyID == 0 {
+		err = ac.ScsManager.RenewToken(r.Context())
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+		}
+	}
+
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "Password Changed",
+	}
+
+	err = dResponseWriter(w, stat, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// ForgotPasswordHandler use for creating a single-use reset token and sending it by the notifier
+func (ac *ApiConfig) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var forgotReq *models.ForgotPasswordRequest = &models.ForgotPasswordRequest{}
+	err := json.NewDecoder(r.Body).Decode(forgotReq)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	// the response is the same for unknown users; so this endpoint does not tell which users exist
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "If the user exists a reset token is sent",
+	}
+
+	// a failure after the user is found is only logged; an error response would tell that the user exists
+	user, err := ac.DHolder.GetUserByID(r.Context(), forgotReq.UserID)
+	if err == nil {
+		err = ac.sendPasswordReset(r.Context(), user)
+		if err != nil {
+			zerolog.Error().Msg(fmt.Sprintf("sending the reset token of user %d: %s", user.ID, err.Error()))
+		}
+	}
+
+	err = dResponseWriter(w, stat, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// sendPasswordReset use for storing a new reset token of user and sending it by the notifier
+func (ac *ApiConfig) sendPasswordReset(ctx context.Context, user *models.Users) error {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	expiresAt := time.Now().Add(ac.Auth.ResetTTL).UTC()
+
+	err = ac.DHolder.AddPasswordReset(ctx, user.ID, hashToken(token), expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return ac.Notifier.NotifyPasswordReset(&notify.PasswordReset{
+		UserID:       user.ID,
+		CompleteName: user.CompleteName,
+		Token:        token,
+		ExpiresAt:    expiresAt,
+	})
+}
+
+// ResetPasswordHandler use for consuming a reset token and storing the new password hash
+func (ac *ApiConfig) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var resetReq *models.ResetPasswordRequest = &models.ResetPasswordRequest{}
+	err := json.NewDecoder(r.Body).Decode(resetReq)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	if !ac.checkPasswordPolicy(w, "new_password", resetReq.NewPassword) {
+		return
+	}
+
+	hashedPass, err := ac.Hasher.Hash(resetReq.NewPassword)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	// the token is only consumed with the new password; a failed update leaves the token usable
+	// and the old password may be leaked, so every refresh token of the user is revoked with them
+	var userID int
+	err = ac.DHolder.WithTx(r.Context(), func(tx repo.Repo) error {
+		userID, err = tx.ConsumePasswordReset(r.Context(), hashToken(resetReq.Token))
+		if err != nil {
+			return err
+		}
+
+		err = tx.UpdateUserPassword(r.Context(), userID, hashedPass)
+		if err != nil {
+			return err
+		}
+
+		return tx.RevokeUserRefreshTokens(r.Context(), userID)
+	})
+	if errors.Is(err, repo.ErrInvalidResetToken) {
+		problem(w, http.StatusBadRequest, CodeInvalidToken, "token", err.Error())
+		return
+	} else if err != nil {
+		failed(w, err)
+		return
+	}
+
+	// the sessions are not in the repository, so they are destroyed after the transaction
+	err = ac.destroyUserSessions(userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+	}
+
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "Password Changed",
+	}
+
+	err = dResponseWriter(w, stat, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
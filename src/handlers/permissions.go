@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	zerolog "github.com/rs/zerolog/log"
+	"net/http"
+)
+
+// Actions that checked by the permission layer
+const (
+	ActionUpdateUser = "update_user"
+	ActionDeleteUser = "delete_user"
+	ActionAddCar     = "add_car"
+	ActionUpdateCar  = "update_car"
+	ActionSetRole    = "set_role"
+)
+
+type principalCtxKey struct{}
+
+// Principal is the authenticated caller of a request with its role;
+// Scopes and ApiKeyID only filled when the caller authenticated by an api key
+// and MFA tells the caller passed two factor authentication
+type Principal struct {
+	UserID   int
+	Role     models.Role
+	Scopes   []string
+	ApiKeyID int
+	MFA      bool
+}
+
+// withPrincipal use for storing the authenticated caller in the request context
+func withPrincipal(r *http.Request, p *Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalCtxKey{}, p))
+}
+
+// PrincipalFromContext use for getting the authenticated caller that RequireAuth stored
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, ok := ctx.Value(principalCtxKey{}).(*Principal)
+	if !ok {
+		return nil
+	}
+
+	return p
+}
+
+// IsAdmin use for checking the caller can do everything
+func (p *Principal) IsAdmin() bool {
+	return p.Role == models.RoleAdmin
+}
+
+// IsStaff use for checking the caller manages other users or their cars
+func (p *Principal) IsStaff() bool {
+	return p.Role == models.RoleAdmin || p.Role == models.RoleFleetManager
+}
+
+// HasScope session callers have every scope and api key callers only have their key scopes
+func (p *Principal) HasScope(scope string) bool {
+	if p.ApiKeyID == 0 {
+		return true
+	}
+
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanManageUser owners can only manage themselves and admins can manage everyone
+func (p *Principal) CanManageUser(userID int) bool {
+	return p.IsAdmin() || p.UserID == userID
+}
+
+// CanManageCar owners can only manage their own cars; admins and fleet managers can manage every car
+func (p *Principal) CanManageCar(ownerID int) bool {
+	return p.IsAdmin() || p.Role == models.RoleFleetManager || p.UserID == ownerID
+}
+
+// forbidden use for writing a 403 response with the denied action
+func forbidden(w http.ResponseWriter, p *Principal, action string) {
+	denied := &models.Problem{
+		Status: http.StatusForbidden,
+		Code:   CodeForbidden,
+		Detail: "you do not have permission to do this action",
+		Action: action,
+	}
+	if p != nil {
+		denied.Role = p.Role
+	}
+
+	writeProblem(w, denied)
+}
+
+// SetRoleHandler use for changing the role of a user; only admins can do it
+func (ac *ApiConfig) SetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.IsAdmin() {
+		forbidden(w, p, ActionSetRole)
+		return
+	}
+
+	var assignment *models.RoleAssignment = &models.RoleAssignment{}
+	err := json.NewDecoder(r.Body).Decode(assignment)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	err = ac.DHolder.SetUserRole(r.Context(), assignment.UserID, assignment.Role)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "Role Changed",
+	}
+
+	err = dResponseWriter(w, stat, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"github.com/DapperBlondie/users-cars-systems/src/validate"
+	zerolog "github.com/rs/zerolog/log"
+	"net/http"
+)
+
+// ProblemContentType is the content type of the error responses
+const ProblemContentType = "application/problem+json"
+
+// Codes of the problems that the api answers
+const (
+	CodeInvalidBody      = "invalid_body"
+	CodeInvalidParam     = "invalid_param"
+	CodeValidation       = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeForeignKey       = "foreign_key_violation"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeUnsupportedType  = "unsupported_media_type"
+	CodeLockedOut        = "locked_out"
+	CodeInvalidToken     = "invalid_token"
+	CodeNotImplemented   = "not_implemented"
+	CodeInternal         = "internal_error"
+	// CodeTwoFactorEnabled and CodeTwoFactorNotEnrolled are the states of two factor authentication that
+	// do not allow the request
+	CodeTwoFactorEnabled     = "two_factor_enabled"
+	CodeTwoFactorNotEnrolled = "two_factor_not_enrolled"
+)
+
+// writeProblem use for writing a problem+json response; its type is about:blank so its title is the http status
+func writeProblem(w http.ResponseWriter, p *models.Problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+
+	outData, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_, err = w.Write(outData)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+	}
+}
+
+// problem use for writing a problem of status by its code, the offending field and the detail for the client
+func problem(w http.ResponseWriter, status int, code, field, detail string) {
+	writeProblem(w, &models.Problem{Status: status, Code: code, Field: field, Detail: detail})
+}
+
+// invalidBody use for answering a body that is not the json that the handler expects
+func invalidBody(w http.ResponseWriter, err error) {
+	problem(w, http.StatusBadRequest, CodeInvalidBody, "", "the body is not valid json: "+err.Error())
+}
+
+// invalidParam use for answering a path or query parameter that can not be parsed
+func invalidParam(w http.ResponseWriter, field, detail string) {
+	problem(w, http.StatusBadRequest, CodeInvalidParam, field, detail)
+}
+
+// invalidField use for answering a field of the body that has an invalid value
+func invalidField(w http.ResponseWriter, field, detail string) {
+	problem(w, http.StatusUnprocessableEntity, CodeValidation, field, detail)
+}
+
+// invalidFields use for answering every field of the body that broke a validation rule at once
+func invalidFields(w http.ResponseWriter, errs validate.Errors) {
+	p := &models.Problem{
+		Status: http.StatusUnprocessableEntity,
+		Code:   CodeValidation,
+		Detail: fmt.Sprintf("%d fields are invalid", len(errs)),
+		Errors: errs,
+	}
+	if len(errs) == 1 {
+		p.Field = errs[0].Field
+		p.Detail = errs[0].Message
+	}
+
+	writeProblem(w, p)
+}
+
+// valid use for checking v by the validate tags of its fields; it answers the invalid fields when there are
+func valid(w http.ResponseWriter, v interface{}) bool {
+	errs := validate.Struct(v)
+	if len(errs) == 0 {
+		return true
+	}
+
+	invalidFields(w, errs)
+	return false
+}
+
+// unauthorized use for answering a request without valid credentials
+func unauthorized(w http.ResponseWriter, detail string) {
+	problem(w, http.StatusUnauthorized, CodeUnauthorized, "", detail)
+}
+
+// methodNotAllowed use for answering a method that the route does not have
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed string) {
+	zerolog.Error().Msg(r.Method + " is not available")
+	w.Header().Set("Allow", allowed)
+	problem(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "", r.Method+" is not available")
+}
+
+// failed use for answering an error of the repository or of the other operations of a handler;
+// the domain errors of repo have their own status and the others are logged and answered by a general 500,
+// so the text of the database never reaches the client
+func failed(w http.ResponseWriter, err error) {
+	var domainErr *repo.Error
+	field, detail := "", ""
+	if errors.As(err, &domainErr) {
+		field, detail = domainErr.Field, domainErr.Message
+	}
+
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		if detail == "" {
+			detail = "the resource is not found"
+		}
+		problem(w, http.StatusNotFound, CodeNotFound, field, detail)
+	case errors.Is(err, repo.ErrConflict):
+		problem(w, http.StatusConflict, CodeConflict, field, detail)
+	case errors.Is(err, repo.ErrValidation):
+		problem(w, http.StatusUnprocessableEntity, CodeValidation, field, detail)
+	case errors.Is(err, repo.ErrForeignKey):
+		if detail == "" {
+			detail = "the request refers to a resource that does not exist"
+		}
+		problem(w, http.StatusBadRequest, CodeForeignKey, field, detail)
+	default:
+		zerolog.Error().Msg(err.Error())
+		problem(w, http.StatusInternalServerError, CodeInternal, "", "the request could not be completed")
+	}
+}
+
+// NotFoundHandler use for answering the routes that do not exist
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	problem(w, http.StatusNotFound, CodeNotFound, "", r.URL.Path+" is not found")
+}
+
+// MethodNotAllowedHandler use for answering the methods that a route does not have
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	problem(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "", r.Method+" is not available")
+}
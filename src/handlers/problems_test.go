@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFailed(t *testing.T) {
+	// secret is the text of a database error that must not reach the client
+	const secret = "near \"SELEC\": syntax error in /var/lib/app-db.db"
+
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		field  string
+	}{
+		{"not found", repo.NotFound("car"), http.StatusNotFound, CodeNotFound, ""},
+		{"no user", repo.NoUser(7), http.StatusNotFound, CodeNotFound, "user_id"},
+		{"wrapped not found", fmt.Errorf("patching: %w", repo.NotFound("user")), http.StatusNotFound, CodeNotFound, ""},
+		{"not found kind", repo.ErrNotFound, http.StatusNotFound, CodeNotFound, ""},
+		{"conflict", repo.UniqueError("cars.vin"), http.StatusConflict, CodeConflict, "vin"},
+		{"validation", repo.Invalid("role", "role is unknown"), http.StatusUnprocessableEntity, CodeValidation, "role"},
+		{"foreign key", repo.ForeignKeyError("owner_id", "there is no user with this id=7"), http.StatusBadRequest, CodeForeignKey, "owner_id"},
+		{"foreign key kind", repo.ErrForeignKey, http.StatusBadRequest, CodeForeignKey, ""},
+		{"no rows", sql.ErrNoRows, http.StatusInternalServerError, CodeInternal, ""},
+		{"database error", errors.New(secret), http.StatusInternalServerError, CodeInternal, ""},
+		{"wrapped database error", fmt.Errorf("listing users: %w", errors.New(secret)), http.StatusInternalServerError, CodeInternal, ""},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		failed(w, c.err)
+
+		if w.Code != c.status {
+			t.Errorf("%s: got %d, want %d", c.name, w.Code, c.status)
+		}
+		if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+			t.Errorf("%s: got the content type %q", c.name, got)
+		}
+
+		var p models.Problem
+		err := json.Unmarshal(w.Body.Bytes(), &p)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if p.Status != c.status || p.Code != c.code || p.Field != c.field {
+			t.Errorf("%s: got %d %s of %q, want %d %s of %q", c.name, p.Status, p.Code, p.Field, c.status, c.code, c.field)
+		}
+		if c.status == http.StatusInternalServerError && (strings.Contains(w.Body.String(), "SELEC") || strings.Contains(w.Body.String(), sql.ErrNoRows.Error())) {
+			t.Errorf("%s: the body has the text of the error: %s", c.name, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	zerolog "github.com/rs/zerolog/log"
+	"net/http"
+	"time"
+)
+
+// issueTokenPair use for signing an access token and storing a new refresh token in the family
+func (ac *ApiConfig) issueTokenPair(ctx context.Context, userID int, familyID string, mfa bool) (*models.TokenPair, error) {
+	role, err := ac.DHolder.GetUserRole(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := ac.Tokens.Issue(userID, string(role), mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(b)
+
+	if familyID == "" {
+		f := make([]byte, 16)
+		_, err = rand.Read(f)
+		if err != nil {
+			return nil, err
+		}
+		familyID = hex.EncodeToString(f)
+	}
+
+	now := time.Now().UTC()
+	err = ac.DHolder.AddRefreshToken(ctx, &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		MFA:       mfa,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ac.Auth.RefreshTTL),
+	}, hashToken(refresh))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(ac.Tokens.TTL().Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+// TokenHandler use for checking user credentials and issuing an access and a refresh token
+func (ac *ApiConfig) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var cred *models.Credentials = &models.Credentials{}
+	err := json.NewDecoder(r.Body).Decode(cred)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	err = ac.checkCredentials(r, cred)
+	if err != nil {
+		credentialsFailed(w, err, "invalid credentials")
+		return
+	}
+
+	mfaEnabled, err := ac.twoFactorEnabled(r.Context(), cred.UserID)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+	if mfaEnabled {
+		if cred.Code == "" && cred.RecoveryCode == "" {
+			status := &models.LoginStatus{
+				Ok:                false,
+				Message:           "Two Factor Code Required",
+				TwoFactorRequired: true,
+			}
+			err = dResponseWriter(w, status, http.StatusUnauthorized)
+			if err != nil {
+				zerolog.Error().Msg(err.Error())
+			}
+			return
+		}
+
+		err = ac.verifySecondFactor(r, cred.UserID, cred.Code, cred.RecoveryCode)
+		if err != nil {
+			authFailed(w, err, "invalid two factor code")
+			return
+		}
+	}
+	ac.clearAuthFailures(r.Context(), cred.UserID)
+
+	pair, err := ac.issueTokenPair(r.Context(), cred.UserID, "", mfaEnabled)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	err = dResponseWriter(w, pair, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// RefreshTokenHandler use for rotating a refresh token; a reused refresh token revokes its whole family
+func (ac *ApiConfig) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var refreshReq *models.RefreshRequest = &models.RefreshRequest{}
+	err := json.NewDecoder(r.Body).Decode(refreshReq)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	stored, err := ac.DHolder.GetRefreshTokenByHash(r.Context(), hashToken(refreshReq.RefreshToken))
+	if err != nil {
+		unauthorized(w, "invalid refresh token")
+		return
+	}
+	if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		unauthorized(w, "invalid refresh token")
+		return
+	}
+
+	err = ac.DHolder.UseRefreshToken(r.Context(), stored.ID)
+	if errors.Is(err, repo.ErrRefreshTokenUsed) {
+		zerolog.Warn().Msg("refresh token reused; revoking its family " + stored.FamilyID)
+		// the revocation must not be cancelled by a client that closes the connection
+		err = ac.DHolder.RevokeRefreshTokenFamily(context.Background(), stored.FamilyID)
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+		}
+		unauthorized(w, "invalid refresh token")
+		return
+	} else if err != nil {
+		failed(w, err)
+		return
+	}
+
+	pair, err := ac.issueTokenPair(r.Context(), stored.UserID, stored.FamilyID, stored.MFA)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	err = dResponseWriter(w, pair, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// RevokeTokenHandler use for revoking a refresh token with every token rotated from it
+func (ac *ApiConfig) RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var refreshReq *models.RefreshRequest = &models.RefreshRequest{}
+	err := json.NewDecoder(r.Body).Decode(refreshReq)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	stored, err := ac.DHolder.GetRefreshTokenByHash(r.Context(), hashToken(refreshReq.RefreshToken))
+	if err == nil {
+		err = ac.DHolder.RevokeRefreshTokenFamily(r.Context(), stored.FamilyID)
+		if err != nil {
+			failed(w, err)
+			return
+		}
+	}
+
+	// unknown tokens are answered the same way; so this endpoint does not tell which tokens exist
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "Token Revoked",
+	}
+
+	err = dResponseWriter(w, stat, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
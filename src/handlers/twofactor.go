@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"github.com/DapperBlondie/users-cars-systems/src/totp"
+	zerolog "github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPIssuer is the name that authenticator apps show for our accounts
+	TOTPIssuer = "users-cars-systems"
+	// SessionMFAPendingKey holding the user that passed the password but not the second factor yet
+	SessionMFAPendingKey = "mfa_pending_user_id"
+	// SessionMFAPendingAtKey holding the unix time that the password step passed
+	SessionMFAPendingAtKey = "mfa_pending_at"
+	// SessionMFAKey tells the logged in user passed two factor authentication
+	SessionMFAKey = "mfa"
+
+	// ActionStaffMFA is the action that denied when staff did not pass two factor authentication
+	ActionStaffMFA = "staff_mfa_required"
+
+	recoveryCodesCount = 10
+	mfaPendingTimeout  = 5 * time.Minute
+)
+
+// twoFactorEnabled use for checking a user confirmed its totp enrollment
+func (ac *ApiConfig) twoFactorEnabled(ctx context.Context, userID int) (bool, error) {
+	t, err := ac.DHolder.GetTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return t.Confirmed, nil
+}
+
+// verifySecondFactor use for checking a totp code or consuming a recovery code of a user;
+// failed codes count with the failed passwords so the codes can not be guessed
+func (ac *ApiConfig) verifySecondFactor(r *http.Request, userID int, code, recoveryCode string) error {
+	err := ac.checkLockout(r, userID)
+	if err != nil {
+		return err
+	}
+
+	err = ac.checkSecondFactor(r.Context(), userID, code, recoveryCode)
+	if err != nil {
+		ac.recordAuthFailure(r, userID)
+		return err
+	}
+
+	return nil
+}
+
+func (ac *ApiConfig) checkSecondFactor(ctx context.Context, userID int, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		return ac.DHolder.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+
+	t, err := ac.DHolder.GetTOTP(ctx, userID)
+	if err != nil {
+		return totp.ErrInvalidCode
+	}
+
+	step, err := totp.DefaultOptions.Validate(t.Secret, code, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// a code can only be used once; so an observed code can not be replayed within its period
+	return ac.DHolder.UseTOTPStep(ctx, userID, step)
+}
+
+// generateRecoveryCodes use for creating the recovery codes with their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j := range b {
+			b[j] = alphabet[int(b[j])%len(alphabet)]
+		}
+
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// EnrollTwoFactorHandler use for generating a totp secret for the logged in user
+func (ac *ApiConfig) EnrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || p.ApiKeyID != 0 {
+		unauthorized(w, "you are not logged in")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = ac.DHolder.SetTOTPSecret(r.Context(), p.UserID, secret)
+	if errors.Is(err, repo.ErrTOTPEnabled) {
+		problem(w, http.StatusConflict, CodeTwoFactorEnabled, "", err.Error())
+		return
+	} else if err != nil {
+		failed(w, err)
+		return
+	}
+
+	enrollment := &models.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.DefaultOptions.URI(TOTPIssuer, fmt.Sprintf("user-%d", p.UserID), secret),
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	err = dResponseWriter(w, enrollment, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// ConfirmTwoFactorHandler use for enabling the totp by its first code and returning the recovery codes
+func (ac *ApiConfig) ConfirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || p.ApiKeyID != 0 {
+		unauthorized(w, "you are not logged in")
+		return
+	}
+
+	var code *models.TwoFactorCode = &models.TwoFactorCode{}
+	err := json.NewDecoder(r.Body).Decode(code)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	t, err := ac.DHolder.GetTOTP(r.Context(), p.UserID)
+	if err != nil {
+		problem(w, http.StatusBadRequest, CodeTwoFactorNotEnrolled, "", "two factor authentication is not enrolled")
+		return
+	}
+	if t.Confirmed {
+		problem(w, http.StatusConflict, CodeTwoFactorEnabled, "", repo.ErrTOTPEnabled.Error())
+		return
+	}
+
+	step, err := totp.DefaultOptions.Validate(t.Secret, code.Code, time.Now())
+	if err != nil {
+		invalidField(w, "code", err.Error())
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = ac.DHolder.ConfirmTOTP(r.Context(), p.UserID, step, hashes)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	err = dResponseWriter(w, &models.RecoveryCodes{Codes: codes}, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// RecoveryCodesHandler use for replacing the recovery codes of the logged in user by a totp code
+func (ac *ApiConfig) RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || p.ApiKeyID != 0 {
+		unauthorized(w, "you are not logged in")
+		return
+	}
+
+	var code *models.TwoFactorCode = &models.TwoFactorCode{}
+	err := json.NewDecoder(r.Body).Decode(code)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	err = ac.verifySecondFactor(r, p.UserID, code.Code, "")
+	if err != nil {
+		authFailed(w, err, "invalid two factor code")
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = ac.DHolder.ReplaceRecoveryCodes(r.Context(), p.UserID, hashes)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	err = dResponseWriter(w, &models.RecoveryCodes{Codes: codes}, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// DisableTwoFactorHandler use for disabling the totp of the logged in user by a totp or recovery code
+func (ac *ApiConfig) DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || p.ApiKeyID != 0 {
+		unauthorized(w, "you are not logged in")
+		return
+	}
+
+	var code *models.TwoFactorCode = &models.TwoFactorCode{}
+	err := json.NewDecoder(r.Body).Decode(code)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	err = ac.verifySecondFactor(r, p.UserID, code.Code, code.RecoveryCode)
+	if err != nil {
+		authFailed(w, err, "invalid two factor code")
+		return
+	}
+
+	err = ac.DHolder.DisableTOTP(r.Context(), p.UserID)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	stat := &models.StatusIdentifier{
+		Ok:      true,
+		Message: "Two Factor Disabled",
+	}
+
+	err = dResponseWriter(w, stat, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// LoginTwoFactorHandler use for the second login step; it logs in the user that passed the password step
+func (ac *ApiConfig) LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	userID := ac.ScsManager.GetInt(r.Context(), SessionMFAPendingKey)
+	pendingAt := time.Unix(int64(ac.ScsManager.GetInt(r.Context(), SessionMFAPendingAtKey)), 0)
+	if userID == 0 || time.Since(pendingAt) > mfaPendingTimeout {
+		unauthorized(w, "login with your password first")
+		return
+	}
+
+	var code *models.TwoFactorCode = &models.TwoFactorCode{}
+	err := json.NewDecoder(r.Body).Decode(code)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		invalidBody(w, err)
+		return
+	}
+
+	err = ac.verifySecondFactor(r, userID, code.Code, code.RecoveryCode)
+	if err != nil {
+		authFailed(w, err, "invalid two factor code")
+		return
+	}
+
+	err = ac.ScsManager.RenewToken(r.Context())
+	if err != nil {
+		failed(w, err)
+		return
+	}
+	ac.ScsManager.Remove(r.Context(), SessionMFAPendingKey)
+	ac.ScsManager.Remove(r.Context(), SessionMFAPendingAtKey)
+	ac.ScsManager.Put(r.Context(), SessionUserKey, userID)
+	ac.ScsManager.Put(r.Context(), SessionMFAKey, true)
+	ac.clearAuthFailures(r.Context(), userID)
+
+	status := &models.LoginStatus{
+		Ok:      true,
+		Message: "Logged In",
+	}
+
+	err = dResponseWriter(w, status, http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
@@ -0,0 +1,478 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/validate"
+	"github.com/go-chi/chi"
+	zerolog "github.com/rs/zerolog/log"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// ActionDeleteCar is the action of deleting a car
+const ActionDeleteCar = "delete_car"
+
+// APIPrefix is the prefix of the versioned routes that replace the legacy ones
+const APIPrefix = "/api/v1"
+
+// MergePatchContentType is the content type of the JSON merge patch of RFC 7396 that the PATCH routes read;
+// they also read application/json as a merge patch
+const MergePatchContentType = "application/merge-patch+json"
+
+// Page size of ListUsersHandler when limit is not in the query and the largest limit it accepts
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// pathID use for reading the integer id of the URL param name; it writes 400 when the id is not an integer
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParamFromCtx(r.Context(), name))
+	if err != nil || id <= 0 {
+		invalidParam(w, name, name+" is not a positive integer")
+		return 0, false
+	}
+
+	return id, true
+}
+
+// queryInt use for reading a non negative integer of the query by its name or def when it is not there
+func queryInt(w http.ResponseWriter, r *http.Request, name string, def int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		invalidParam(w, name, name+" is not a non negative integer")
+		return 0, false
+	}
+
+	return n, true
+}
+
+// mergePatch use for reading the JSON merge patch of the body into patch, a struct of pointer fields;
+// the members that patch does not have, the null members and the values that break the rules of patch
+// are answered at once
+func mergePatch(w http.ResponseWriter, r *http.Request, patch interface{}) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MergePatchContentType && mediaType != "application/json" {
+		w.Header().Set("Accept-Patch", MergePatchContentType)
+		problem(w, http.StatusUnsupportedMediaType, CodeUnsupportedType, "", "the body must be "+MergePatchContentType)
+		return false
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		invalidBody(w, err)
+		return false
+	}
+
+	// a merge patch that is not an object would replace the whole resource, which PUT does
+	var members map[string]json.RawMessage
+	err = json.Unmarshal(data, &members)
+	if err != nil && !json.Valid(data) {
+		invalidBody(w, err)
+		return false
+	}
+	if err != nil || members == nil {
+		problem(w, http.StatusBadRequest, CodeInvalidBody, "", "the merge patch is not a json object")
+		return false
+	}
+
+	err = json.Unmarshal(data, patch)
+	if err != nil {
+		invalidBody(w, err)
+		return false
+	}
+
+	errs := append(validate.Patch(members, patch), validate.Struct(patch)...)
+	if len(errs) > 0 {
+		invalidFields(w, errs)
+		return false
+	}
+
+	return true
+}
+
+// writeCreated use for answering 201 with the location of the new resource
+func writeCreated(w http.ResponseWriter, location string, data interface{}) {
+	w.Header().Set("Location", location)
+
+	err := dResponseWriter(w, data, http.StatusCreated)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+	}
+}
+
+// ListUsersHandler use for listing users with their cars by the optional limit & offset of the query
+func (ac *ApiConfig) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	limit, ok := queryInt(w, r, "limit", DefaultPageLimit)
+	if !ok {
+		return
+	}
+	offset, ok := queryInt(w, r, "offset", 0)
+	if !ok {
+		return
+	}
+	if limit == 0 || limit > MaxPageLimit {
+		invalidParam(w, "limit", fmt.Sprintf("limit must be between 1 and %d", MaxPageLimit))
+		return
+	}
+
+	users, err := ac.DHolder.GetAllUsers(r.Context(), limit, offset)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+	err = dResponseWriter(w, models.NewUserViews(users), http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// CreateUserHandler use for adding a user like AddUserHandler; it answers 201 with the new user
+func (ac *ApiConfig) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	user, ok := ac.addUser(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := ac.DHolder.GetUserByID(r.Context(), user.ID)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	writeCreated(w, fmt.Sprintf("%s/users/%d", APIPrefix, created.ID), models.NewUserView(created))
+	return
+}
+
+// PatchUserHandler use for changing the fields of a user profile that are in the merge patch of the body;
+// the other fields stay and it answers the updated user
+func (ac *ApiConfig) PatchUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		methodNotAllowed(w, r, http.MethodPatch)
+		return
+	}
+
+	id, ok := pathID(w, r, "user_id")
+	if !ok {
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageUser(id) {
+		forbidden(w, p, ActionUpdateUser)
+		return
+	}
+
+	var patch *models.UserPatch = &models.UserPatch{}
+	if !mergePatch(w, r, patch) {
+		return
+	}
+
+	user, err := ac.DHolder.PatchUser(r.Context(), id, patch)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = dResponseWriter(w, models.NewUserView(user), http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// PutUserHandler use for replacing the whole profile of a user; the fields that are not in the body are cleared
+func (ac *ApiConfig) PutUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		methodNotAllowed(w, r, http.MethodPut)
+		return
+	}
+
+	id, ok := pathID(w, r, "user_id")
+	if !ok {
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageUser(id) {
+		forbidden(w, p, ActionUpdateUser)
+		return
+	}
+
+	_, err := ac.DHolder.GetUserByID(r.Context(), id)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	var body *models.UserUpdate = &models.UserUpdate{}
+	err = json.NewDecoder(r.Body).Decode(body)
+	if err != nil {
+		invalidBody(w, err)
+		return
+	}
+
+	ac.saveUser(w, r, id, body)
+	return
+}
+
+// saveUser use for storing the profile of body as the user of id and answering the stored user;
+// the id of the body is ignored
+func (ac *ApiConfig) saveUser(w http.ResponseWriter, r *http.Request, id int, body *models.UserUpdate) {
+	if !valid(w, body) {
+		return
+	}
+
+	err := ac.DHolder.UpdateUser(r.Context(), body.User(id))
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	updated, err := ac.DHolder.GetUserByID(r.Context(), id)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = dResponseWriter(w, models.NewUserView(updated), http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+	}
+}
+
+// RemoveUserHandler use for deleting a user with its cars; it answers 204
+func (ac *ApiConfig) RemoveUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w, r, http.MethodDelete)
+		return
+	}
+
+	id, ok := pathID(w, r, "user_id")
+	if !ok {
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageUser(id) {
+		forbidden(w, p, ActionDeleteUser)
+		return
+	}
+
+	_, err := ac.DHolder.GetUserByID(r.Context(), id)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = ac.DHolder.DeleteUser(r.Context(), id)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
+
+// GetUserCarsHandler use for listing the cars of a user
+func (ac *ApiConfig) GetUserCarsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	id, ok := pathID(w, r, "user_id")
+	if !ok {
+		return
+	}
+
+	user, err := ac.DHolder.GetUserByID(r.Context(), id)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = dResponseWriter(w, models.NewCarViews(user.UsersCars), http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// CreateUserCarHandler use for adding a car of the user in the path; it answers 201 with the new car
+func (ac *ApiConfig) CreateUserCarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	ownerID, ok := pathID(w, r, "user_id")
+	if !ok {
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageCar(ownerID) {
+		forbidden(w, p, ActionAddCar)
+		return
+	}
+
+	_, err := ac.DHolder.GetUserByID(r.Context(), ownerID)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	var body *models.CarCreate = &models.CarCreate{}
+	err = json.NewDecoder(r.Body).Decode(body)
+	if err != nil {
+		invalidBody(w, err)
+		return
+	}
+	if !valid(w, body) {
+		return
+	}
+
+	car := body.Car(ownerID)
+	err = ac.DHolder.AddCar(r.Context(), car)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	writeCreated(w, fmt.Sprintf("%s/cars/%d", APIPrefix, car.ID), models.NewCarView(car))
+	return
+}
+
+// GetCarHandler use for getting a car by its id
+func (ac *ApiConfig) GetCarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	id, ok := pathID(w, r, "car_id")
+	if !ok {
+		return
+	}
+
+	car, err := ac.DHolder.GetCarByID(r.Context(), id)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = dResponseWriter(w, models.NewCarView(car), http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// PatchCarHandler use for changing the fields of a car that are in the merge patch of the body;
+// its owner can not be changed and it answers the updated car
+func (ac *ApiConfig) PatchCarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		methodNotAllowed(w, r, http.MethodPatch)
+		return
+	}
+
+	id, ok := pathID(w, r, "car_id")
+	if !ok {
+		return
+	}
+
+	car, err := ac.DHolder.GetCarByID(r.Context(), id)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageCar(car.OwnerID) {
+		forbidden(w, p, ActionUpdateCar)
+		return
+	}
+
+	var patch *models.CarPatch = &models.CarPatch{}
+	if !mergePatch(w, r, patch) {
+		return
+	}
+
+	updated, err := ac.DHolder.PatchCar(r.Context(), id, patch)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	err = dResponseWriter(w, models.NewCarView(updated), http.StatusOK)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return
+	}
+
+	return
+}
+
+// RemoveCarHandler use for deleting a car by its id; it answers 204
+func (ac *ApiConfig) RemoveCarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w, r, http.MethodDelete)
+		return
+	}
+
+	id, ok := pathID(w, r, "car_id")
+	if !ok {
+		return
+	}
+
+	car, err := ac.DHolder.GetCarByID(r.Context(), id)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	p := PrincipalFromContext(r.Context())
+	if p == nil || !p.CanManageCar(car.OwnerID) {
+		forbidden(w, p, ActionDeleteCar)
+		return
+	}
+
+	err = ac.DHolder.DeleteCar(r.Context(), id)
+	if err != nil {
+		failed(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return
+}
@@ -0,0 +1,419 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	zerolog "github.com/rs/zerolog/log"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SchemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations
+( version integer NOT NULL PRIMARY KEY , name varchar(127) NOT NULL , applied_at datetime NOT NULL )`
+	PostgresSchemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations
+( version integer NOT NULL PRIMARY KEY , name varchar(127) NOT NULL , applied_at timestamptz NOT NULL )`
+)
+
+// Names of the database/sql drivers that have migrations; they are the names that the repo package opens
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrUnknownVersion returned when a target version is not one of the migrations
+var ErrUnknownVersion = errors.New("migration version is unknown")
+
+// Migration holding the sql that moves the schema to its version and back
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status holding a migration with the time it is applied; AppliedAt is nil for pending migrations
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator applying the embedded migrations to a database and recording them in schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []*Migration
+}
+
+// New use for creating a Migrator of the embedded migrations of driver; sqlite and postgres have their own
+// files with the same versions
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	var dir string
+	switch driver {
+	case DriverSQLite:
+		dir = "sqlite"
+	case DriverPostgres:
+		dir = "postgres"
+	default:
+		return nil, fmt.Errorf("there is no migrations for the %s driver", driver)
+	}
+
+	migrations, err := load(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, driver: driver, migrations: migrations}, nil
+}
+
+// load use for reading the NNNN_name.up.sql and NNNN_name.down.sql files of dir in the order of their versions
+func load(files fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		parts := fileName.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("migration file %s is not named like 0001_name.up.sql", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(parts[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		} else if m.Name != parts[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, parts[2])
+		}
+
+		content, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if parts[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest use for getting the version of the newest migration
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version use for getting the newest applied version of the database; 0 means nothing is applied
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	err := m.prepare(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	err = m.db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// Status use for listing every migration with the time it is applied
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := &Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			at := at
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+
+	return statuses, nil
+}
+
+// Up use for applying every pending migration
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down use for rolling back the newest applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+
+	target := 0
+	for _, mig := range m.migrations {
+		if mig.Version < version {
+			target = mig.Version
+		}
+	}
+
+	return m.To(ctx, target)
+}
+
+// To use for applying the pending migrations up to target and rolling back the applied migrations after target;
+// every migration runs in its own transaction with its schema_migrations row
+func (m *Migrator) To(ctx context.Context, target int) error {
+	if target != 0 && m.find(target) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, target)
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok || mig.Version <= target {
+			continue
+		}
+
+		err = m.run(ctx, mig, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok || mig.Version > target {
+			continue
+		}
+
+		err = m.run(ctx, mig, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) run(ctx context.Context, mig *Migration, up bool) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	direction, done := "up", "applied"
+	if up {
+		_, err = tx.ExecContext(ctx, mig.Up)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, m.bind(`INSERT INTO schema_migrations (version,name,applied_at) VALUES (?,?,?)`),
+				mig.Version, mig.Name, time.Now().UTC())
+		}
+	} else {
+		direction, done = "down", "rolled back"
+		_, err = tx.ExecContext(ctx, mig.Down)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, m.bind(`DELETE FROM schema_migrations WHERE version=?`), mig.Version)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("migration %04d_%s %s: %w", mig.Version, mig.Name, direction, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	zerolog.Info().Msg(fmt.Sprintf("migration %04d_%s is %s", mig.Version, mig.Name, done))
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := m.db.QueryContext(ctx, `SELECT version,applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	applied := map[int]time.Time{}
+	for results.Next() {
+		var version int
+		var at time.Time
+		err = results.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	return applied, results.Err()
+}
+
+// prepare use for creating schema_migrations; a database that CreateTables created before migrations existed
+// is adopted by recording the versions that its tables already have
+func (m *Migrator) prepare(ctx context.Context) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists, err := m.hasTable(ctx, tx, "schema_migrations")
+	if err != nil || exists {
+		return err
+	}
+
+	if m.driver == DriverPostgres {
+		// postgres databases never had tables that the migrations did not create
+		_, err = tx.ExecContext(ctx, PostgresSchemaMigrationsTable)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	_, err = tx.ExecContext(ctx, SchemaMigrationsTable)
+	if err != nil {
+		return err
+	}
+
+	err = m.adoptLegacy(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// adoptLegacy use for recording the versions of a database that CreateTables made before the migrations;
+// it has the users and cars of version 1 and the role column of version 2 when it is there
+func (m *Migrator) adoptLegacy(ctx context.Context, tx *sql.Tx) error {
+	hasUsers, err := m.hasTable(ctx, tx, "users")
+	if err != nil || !hasUsers {
+		return err
+	}
+
+	baseline := []int{1}
+	hasRole, err := hasColumn(ctx, tx, "users", "role")
+	if err != nil {
+		return err
+	}
+	if hasRole {
+		baseline = append(baseline, 2)
+	}
+
+	for _, version := range baseline {
+		mig := m.find(version)
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version,name,applied_at) VALUES (?,?,?)`,
+			mig.Version, mig.Name, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+	}
+	zerolog.Info().Msg(fmt.Sprintf("existing database is adopted at version %d", baseline[len(baseline)-1]))
+
+	return nil
+}
+
+func (m *Migrator) hasTable(ctx context.Context, tx *sql.Tx, table string) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?`
+	if m.driver == DriverPostgres {
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema=current_schema() AND table_name=$1`
+	}
+
+	var count int
+	err := tx.QueryRowContext(ctx, query, table).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func hasColumn(ctx context.Context, tx *sql.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?`, table, column).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// bind use for replacing the ? placeholders of a query by $1, $2, ... for postgres
+func (m *Migrator) bind(query string) string {
+	if m.driver != DriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS users
+( id serial NOT NULL PRIMARY KEY , com_name varchar(63) NOT NULL , sex boolean NOT NULL , birthday varchar(31) NOT NULL , password varchar(255) NOT NULL );
+
+CREATE TABLE IF NOT EXISTS cars
+( id serial NOT NULL PRIMARY KEY , number_plate varchar(31) NOT NULL , color varchar(15) NOT NULL , vin varchar(31) NOT NULL , owner_id integer NOT NULL , CONSTRAINT vin_idx UNIQUE ( vin ) , CONSTRAINT num_idx UNIQUE ( number_plate ) , FOREIGN KEY ( owner_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS sessions
+( token varchar(43) NOT NULL PRIMARY KEY , data bytea NOT NULL , expiry bigint NOT NULL );
+
+CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions ( expiry );
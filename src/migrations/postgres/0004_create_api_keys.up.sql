@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS api_keys
+( id serial NOT NULL PRIMARY KEY , user_id integer NOT NULL , name varchar(63) NOT NULL , prefix varchar(15) NOT NULL , key_hash varchar(64) NOT NULL , scopes varchar(255) NOT NULL DEFAULT '' , created_at timestamptz NOT NULL , last_used_at timestamptz , expires_at timestamptz , revoked_at timestamptz , CONSTRAINT key_hash_idx UNIQUE ( key_hash ) , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
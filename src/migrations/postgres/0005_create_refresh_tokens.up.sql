@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens
+( id serial NOT NULL PRIMARY KEY , user_id integer NOT NULL , token_hash varchar(64) NOT NULL , family_id varchar(32) NOT NULL , created_at timestamptz NOT NULL , expires_at timestamptz NOT NULL , used_at timestamptz , revoked_at timestamptz , mfa boolean NOT NULL DEFAULT false , CONSTRAINT token_hash_idx UNIQUE ( token_hash ) , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens ( family_id );
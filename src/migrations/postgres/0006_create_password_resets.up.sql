@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS password_resets
+( id serial NOT NULL PRIMARY KEY , user_id integer NOT NULL , token_hash varchar(64) NOT NULL , created_at timestamptz NOT NULL , expires_at timestamptz NOT NULL , used_at timestamptz , CONSTRAINT reset_hash_idx UNIQUE ( token_hash ) , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS user_totp
+( user_id integer NOT NULL PRIMARY KEY , secret varchar(63) NOT NULL , created_at timestamptz NOT NULL , confirmed_at timestamptz , last_step bigint NOT NULL DEFAULT 0 , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
+
+CREATE TABLE IF NOT EXISTS recovery_codes
+( id serial NOT NULL PRIMARY KEY , user_id integer NOT NULL , code_hash varchar(64) NOT NULL , used_at timestamptz , CONSTRAINT code_hash_idx UNIQUE ( code_hash ) , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS auth_failures
+( kind varchar(7) NOT NULL , subject varchar(63) NOT NULL , failures integer NOT NULL DEFAULT 0 , last_failure timestamptz NOT NULL , locked_until timestamptz , PRIMARY KEY ( kind , subject ) );
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS cars_owner_idx ON cars ( owner_id );
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN role varchar(15) NOT NULL DEFAULT 'owner';
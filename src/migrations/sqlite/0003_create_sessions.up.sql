@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS sessions
+( token char(43) NOT NULL PRIMARY KEY , data blob NOT NULL , expiry integer NOT NULL );
+
+CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions ( expiry );
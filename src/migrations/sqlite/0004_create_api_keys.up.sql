@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS api_keys
+( id integer NOT NULL PRIMARY KEY autoincrement , user_id integer NOT NULL , name varchar(63) NOT NULL , prefix varchar(15) NOT NULL , key_hash char(64) NOT NULL , scopes varchar(255) NOT NULL DEFAULT '' , created_at datetime NOT NULL , last_used_at datetime , expires_at datetime , revoked_at datetime , CONSTRAINT key_hash_idx UNIQUE ( key_hash ) , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
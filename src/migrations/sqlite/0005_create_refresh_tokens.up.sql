@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens
+( id integer NOT NULL PRIMARY KEY autoincrement , user_id integer NOT NULL , token_hash char(64) NOT NULL , family_id char(32) NOT NULL , created_at datetime NOT NULL , expires_at datetime NOT NULL , used_at datetime , revoked_at datetime , mfa boolean NOT NULL DEFAULT 0 , CONSTRAINT token_hash_idx UNIQUE ( token_hash ) , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens ( family_id );
@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS password_resets
+( id integer NOT NULL PRIMARY KEY autoincrement , user_id integer NOT NULL , token_hash char(64) NOT NULL , created_at datetime NOT NULL , expires_at datetime NOT NULL , used_at datetime , CONSTRAINT reset_hash_idx UNIQUE ( token_hash ) , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
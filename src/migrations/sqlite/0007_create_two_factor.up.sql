@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS user_totp
+( user_id integer NOT NULL PRIMARY KEY , secret varchar(63) NOT NULL , created_at datetime NOT NULL , confirmed_at datetime , last_step integer NOT NULL DEFAULT 0 , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
+
+CREATE TABLE IF NOT EXISTS recovery_codes
+( id integer NOT NULL PRIMARY KEY autoincrement , user_id integer NOT NULL , code_hash char(64) NOT NULL , used_at datetime , CONSTRAINT code_hash_idx UNIQUE ( code_hash ) , FOREIGN KEY ( user_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE );
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS cars_owner_idx;
@@ -0,0 +1,159 @@
+package models
+
+// UserCreate holding the payload for adding a user; Password is the plain password that is hashed before storing
+type UserCreate struct {
+	CompleteName string `json:"complete_name" validate:"required,max=63"`
+	Sex          bool   `json:"sex"`
+	BirthDay     string `json:"birth_day" validate:"required,date"`
+	Password     string `json:"password"`
+}
+
+// UserUpdate holding the payload for changing the profile of a user; ID is only read by the legacy route
+// that has no id in its path. Passwords and roles have their own routes
+type UserUpdate struct {
+	ID           int    `json:"id,omitempty"`
+	CompleteName string `json:"complete_name" validate:"required,max=63"`
+	Sex          bool   `json:"sex"`
+	BirthDay     string `json:"birth_day" validate:"required,date"`
+}
+
+// UserPatch holding a JSON merge patch of the profile of a user; a nil field is not in the patch
+// and it is not changed
+type UserPatch struct {
+	CompleteName *string `json:"complete_name" validate:"required,max=63"`
+	Sex          *bool   `json:"sex"`
+	BirthDay     *string `json:"birth_day" validate:"required,date"`
+}
+
+// UserView holding a user as it is answered to clients; it never has the password
+type UserView struct {
+	ID           int        `json:"id"`
+	CompleteName string     `json:"complete_name"`
+	Sex          bool       `json:"sex"`
+	BirthDay     string     `json:"birth_day"`
+	Role         Role       `json:"role"`
+	UsersCars    []*CarView `json:"users_cars,omitempty"`
+}
+
+// CarCreate holding the payload for adding a car; OwnerID is only read by the legacy route,
+// the versioned route takes the owner from its path
+type CarCreate struct {
+	NumberPlate string `json:"number_plate" validate:"required,min=2,max=12,plate"`
+	Color       string `json:"color" validate:"required,max=15"`
+	VIN         string `json:"vin" validate:"required,vin"`
+	OwnerID     int    `json:"owner_id,omitempty"`
+}
+
+// CarUpdate holding the payload for changing a car; ID is only read by the legacy route and the owner
+// can not be changed
+type CarUpdate struct {
+	ID          int    `json:"id,omitempty"`
+	NumberPlate string `json:"number_plate" validate:"required,min=2,max=12,plate"`
+	Color       string `json:"color" validate:"required,max=15"`
+	VIN         string `json:"vin" validate:"required,vin"`
+}
+
+// CarPatch holding a JSON merge patch of a car; a nil field is not in the patch and it is not changed
+type CarPatch struct {
+	NumberPlate *string `json:"number_plate" validate:"required,min=2,max=12,plate"`
+	Color       *string `json:"color" validate:"required,max=15"`
+	VIN         *string `json:"vin" validate:"required,vin"`
+}
+
+// CarView holding a car as it is answered to clients
+type CarView struct {
+	ID          int    `json:"id"`
+	NumberPlate string `json:"number_plate"`
+	Color       string `json:"color"`
+	VIN         string `json:"vin"`
+	OwnerID     int    `json:"owner_id"`
+}
+
+// User use for mapping the payload to a new user; its role is always RoleOwner
+func (u *UserCreate) User() *Users {
+	return &Users{
+		CompleteName: u.CompleteName,
+		Sex:          u.Sex,
+		BirthDay:     u.BirthDay,
+		Password:     u.Password,
+		Role:         RoleOwner,
+	}
+}
+
+// User use for mapping the payload to the user of id
+func (u *UserUpdate) User(id int) *Users {
+	return &Users{
+		ID:           id,
+		CompleteName: u.CompleteName,
+		Sex:          u.Sex,
+		BirthDay:     u.BirthDay,
+	}
+}
+
+// NewUserView use for mapping a stored user with its cars to its view
+func NewUserView(user *Users) *UserView {
+	view := &UserView{
+		ID:           user.ID,
+		CompleteName: user.CompleteName,
+		Sex:          user.Sex,
+		BirthDay:     user.BirthDay,
+		Role:         user.Role,
+	}
+	if user.UsersCars != nil {
+		view.UsersCars = NewCarViews(user.UsersCars)
+	}
+
+	return view
+}
+
+// NewUserViews use for mapping stored users to their views; it is never nil
+func NewUserViews(users []*Users) []*UserView {
+	views := make([]*UserView, 0, len(users))
+	for _, user := range users {
+		views = append(views, NewUserView(user))
+	}
+
+	return views
+}
+
+// Car use for mapping the payload to a new car of ownerID
+func (c *CarCreate) Car(ownerID int) *Cars {
+	return &Cars{
+		NumberPlate: c.NumberPlate,
+		Color:       c.Color,
+		VIN:         c.VIN,
+		OwnerID:     ownerID,
+	}
+}
+
+// Car use for mapping the payload to the car of id that ownerID owns
+func (c *CarUpdate) Car(id, ownerID int) *Cars {
+	return &Cars{
+		ID:          id,
+		NumberPlate: c.NumberPlate,
+		Color:       c.Color,
+		VIN:         c.VIN,
+		OwnerID:     ownerID,
+	}
+}
+
+// NewCarView use for mapping a stored car to its view
+func NewCarView(car *Cars) *CarView {
+	return &CarView{
+		ID:          car.ID,
+		NumberPlate: car.NumberPlate,
+		Color:       car.Color,
+		VIN:         car.VIN,
+		OwnerID:     car.OwnerID,
+	}
+}
+
+// NewCarViews use for mapping stored cars to their views; it is never nil
+func NewCarViews(cars []*Cars) []*CarView {
+	views := make([]*CarView, 0, len(cars))
+	for _, car := range cars {
+		views = append(views, NewCarView(car))
+	}
+
+	return views
+}
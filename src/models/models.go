@@ -1,17 +1,62 @@
 package models
 
+import "time"
+
 type StatusIdentifier struct {
 	Ok      bool   `json:"ok"`
 	Message string `json:"message"`
 }
 
-// Users holding users data that stored in DB in a structures
+// Role is the authorization level of a user
+type Role string
+
+const (
+	RoleAdmin        Role = "admin"
+	RoleFleetManager Role = "fleet_manager"
+	RoleOwner        Role = "owner"
+)
+
+// Valid use for checking the role is one of the known roles
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleFleetManager, RoleOwner:
+		return true
+	}
+
+	return false
+}
+
+// Problem holding an error response of RFC 7807; Code is the machine readable reason and Field is the field
+// of the request that caused it. Action and Role are only filled when a permission check denied the request
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+	Field  string `json:"field,omitempty"`
+	Action string `json:"action,omitempty"`
+	Role   Role   `json:"role,omitempty"`
+	// Errors has every invalid field of the request when more than one field is checked
+	Errors []*FieldError `json:"errors,omitempty"`
+}
+
+// FieldError holding a field of the request that broke a validation rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Users holding users data that stored in DB in a structures; the handlers read UserCreate or UserUpdate
+// and answer UserView, so Password is never marshaled
 type Users struct {
 	ID           int     `json:"id,omitempty"`
 	CompleteName string  `json:"complete_name"`
 	Sex          bool    `json:"sex"`
 	BirthDay     string  `json:"birth_day"`
-	Password     string  `json:"password"`
+	Password     string  `json:"-"`
+	Role         Role    `json:"role,omitempty"`
 	UsersCars    []*Cars `json:"users_cars,omitempty"`
 }
 
@@ -23,3 +68,152 @@ type Cars struct {
 	VIN         string `json:"vin"`
 	OwnerID     int    `json:"owner_id"`
 }
+
+// Credentials holding the login payload of a user; Code or RecoveryCode only needed in token mode
+// when the user enabled two factor authentication
+type Credentials struct {
+	UserID       int    `json:"user_id"`
+	Password     string `json:"password"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// LoginStatus holding the result of a login; when TwoFactorRequired the session is not logged in
+// until the code is sent to the second login step
+type LoginStatus struct {
+	Ok                bool   `json:"ok"`
+	Message           string `json:"message"`
+	TwoFactorRequired bool   `json:"two_factor_required"`
+}
+
+// RoleAssignment holding the payload for changing the role of a user
+type RoleAssignment struct {
+	UserID int  `json:"user_id"`
+	Role   Role `json:"role"`
+}
+
+// Scopes that an ApiKey can be limited to
+const (
+	ScopeUsersWrite = "users:write"
+	ScopeCarsWrite  = "cars:write"
+)
+
+// ApiKey holding the data of a machine client key; the key itself never stored
+type ApiKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ApiKeyRequest holding the payload for issuing an ApiKey
+type ApiKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// IssuedApiKey holding a new ApiKey with its plain key that only shown once
+type IssuedApiKey struct {
+	Key    string  `json:"key"`
+	ApiKey *ApiKey `json:"api_key"`
+}
+
+// RefreshToken holding the data of a rotating refresh token; the token itself never stored
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	MFA       bool
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// TokenPair holding the tokens that issued for token mode clients
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshRequest holding the payload for refreshing or revoking a refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ForgotPasswordRequest holding the payload for requesting a password reset
+type ForgotPasswordRequest struct {
+	UserID int `json:"user_id"`
+}
+
+// ResetPasswordRequest holding the payload for resetting a password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// ChangePasswordRequest holding the payload for changing a password by the current password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// TOTP holding the two factor enrollment of a user
+type TOTP struct {
+	UserID    int
+	Secret    string
+	Confirmed bool
+	LastStep  int64
+}
+
+// TwoFactorEnrollment holding the secret that the user adds to its authenticator app
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// TwoFactorCode holding a one-time password or a recovery code
+type TwoFactorCode struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// RecoveryCodes holding the recovery codes that only shown once
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// Kinds of the subjects that failed attempts are counted for
+const (
+	FailureKindUser = "user"
+	FailureKindIP   = "ip"
+)
+
+// AuthFailure holding the failed authentication attempts of a user or a client ip
+type AuthFailure struct {
+	Kind        string     `json:"kind"`
+	Subject     string     `json:"subject"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	Locked      bool       `json:"locked"`
+}
+
+// LockedAt use for checking if the subject is still locked out at now
+func (f *AuthFailure) LockedAt(now time.Time) bool {
+	return f.LockedUntil != nil && f.LockedUntil.After(now)
+}
+
+// UnlockRequest holding the user or the ip that an admin unlocks
+type UnlockRequest struct {
+	UserID int    `json:"user_id,omitempty"`
+	IP     string `json:"ip,omitempty"`
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"encoding/json"
+	zerolog "github.com/rs/zerolog/log"
+	"os"
+	"sync"
+	"time"
+)
+
+// PasswordReset holding what a user needs for resetting its password
+type PasswordReset struct {
+	UserID       int       `json:"user_id"`
+	CompleteName string    `json:"complete_name"`
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Notifier delivers the secrets that only the user should see; e.g. by email or sms
+type Notifier interface {
+	NotifyPasswordReset(reset *PasswordReset) error
+}
+
+// LogNotifier writes the notifications into the log; only use it for local testing
+type LogNotifier struct{}
+
+// NewLogNotifier use for creating a LogNotifier
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (l *LogNotifier) NotifyPasswordReset(reset *PasswordReset) error {
+	zerolog.Info().
+		Int("user_id", reset.UserID).
+		Str("token", reset.Token).
+		Time("expires_at", reset.ExpiresAt).
+		Msg("password reset requested")
+
+	return nil
+}
+
+// FileNotifier appends every notification as a json line into a file
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewFileNotifier use for creating a FileNotifier that writes into path
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{Path: path}
+}
+
+func (f *FileNotifier) NotifyPasswordReset(reset *PasswordReset) error {
+	line, err := json.Marshal(reset)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,231 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// Names of the hashing algorithms
+const (
+	AlgBcrypt   = "bcrypt"
+	AlgArgon2id = "argon2id"
+)
+
+var (
+	// ErrMismatch returned when a password does not match its hash
+	ErrMismatch = errors.New("password does not match")
+	// ErrUnknownHash returned when no algorithm can read a stored hash
+	ErrUnknownHash = errors.New("password hash format is unknown")
+)
+
+// Algorithm is a password hashing algorithm that stores its name and parameters in its hashes
+type Algorithm interface {
+	Name() string
+	Hash(password string) (string, error)
+	// Identify tells the hash is created by this algorithm
+	Identify(hash string) bool
+	Verify(hash, password string) error
+	// Outdated tells the hash is created by other parameters than the current ones
+	Outdated(hash string) bool
+}
+
+// Hasher hashing new passwords by its preferred algorithm and verifying the hashes of every known algorithm
+type Hasher struct {
+	preferred  Algorithm
+	algorithms []Algorithm
+}
+
+// NewHasher use for creating a Hasher that hashes by the algorithm with the alg name
+func NewHasher(alg string, bcryptCost int, argon Argon2idParams) (*Hasher, error) {
+	h := &Hasher{
+		algorithms: []Algorithm{
+			&Bcrypt{Cost: bcryptCost},
+			&Argon2id{Params: argon},
+		},
+	}
+
+	for _, a := range h.algorithms {
+		if a.Name() == alg {
+			h.preferred = a
+		}
+	}
+	if h.preferred == nil {
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", alg)
+	}
+
+	return h, nil
+}
+
+// Hash use for hashing a new password by the preferred algorithm
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify use for checking a password against its stored hash; rehash tells the hash should be replaced
+// by a new one because its algorithm or parameters are not the preferred ones anymore
+func (h *Hasher) Verify(hash, password string) (rehash bool, err error) {
+	for _, a := range h.algorithms {
+		if !a.Identify(hash) {
+			continue
+		}
+
+		err = a.Verify(hash, password)
+		if err != nil {
+			return false, err
+		}
+
+		return a != h.preferred || a.Outdated(hash), nil
+	}
+
+	return false, ErrUnknownHash
+}
+
+// Bcrypt is the bcrypt algorithm; its hashes look like $2a$12$...
+type Bcrypt struct {
+	Cost int
+}
+
+// Name use for getting the name of the algorithm
+func (b *Bcrypt) Name() string {
+	return AlgBcrypt
+}
+
+// Hash use for hashing a password by the cost
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// Identify use for checking the hash is a bcrypt hash
+func (b *Bcrypt) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Verify use for comparing a password with a bcrypt hash
+func (b *Bcrypt) Verify(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+
+	return err
+}
+
+// Outdated use for checking the cost of a bcrypt hash is different from the current cost
+func (b *Bcrypt) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.Cost
+}
+
+// Argon2idParams holding the parameters of argon2id; Memory is in KiB
+type Argon2idParams struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// Argon2id is the argon2id algorithm; its hashes use the PHC format $argon2id$v=19$m=65536,t=3,p=2$salt$key
+type Argon2id struct {
+	Params Argon2idParams
+}
+
+// Name use for getting the name of the algorithm
+func (a *Argon2id) Name() string {
+	return AlgArgon2id
+}
+
+// Hash use for hashing a password by a random salt and the params
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.Params.SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Params.Time, a.Params.Memory, a.Params.Threads, a.Params.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		a.Params.Memory,
+		a.Params.Time,
+		a.Params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Identify use for checking the hash is an argon2id hash
+func (a *Argon2id) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// Verify use for hashing the password by the salt and params of the hash and comparing the keys in constant time
+func (a *Argon2id) Verify(hash, password string) error {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+// Outdated use for checking the params of an argon2id hash are different from the current params
+func (a *Argon2id) Outdated(hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Time != a.Params.Time ||
+		params.Memory != a.Params.Memory ||
+		params.Threads != a.Params.Threads ||
+		uint32(len(salt)) != a.Params.SaltLen ||
+		uint32(len(key)) != a.Params.KeyLen
+}
+
+func decodeArgon2id(hash string) (*Argon2idParams, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgArgon2id {
+		return nil, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrUnknownHash
+	}
+
+	params := &Argon2idParams{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return nil, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrUnknownHash
+	}
+
+	return params, salt, key, nil
+}
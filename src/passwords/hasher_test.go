@@ -0,0 +1,121 @@
+package passwords
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+const testPassword = "longsecret42"
+
+// testArgon2idParams are small params that keep the tests fast
+var testArgon2idParams = Argon2idParams{Time: 1, Memory: 64, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+func mustHasher(t *testing.T, alg string, bcryptCost int, argon Argon2idParams) *Hasher {
+	t.Helper()
+	h, err := NewHasher(alg, bcryptCost, argon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+func mustHash(t *testing.T, h *Hasher, password string) string {
+	t.Helper()
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func TestHasherVerify(t *testing.T) {
+	bcryptHasher := mustHasher(t, AlgBcrypt, bcrypt.MinCost, testArgon2idParams)
+	argonHasher := mustHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2idParams)
+
+	slower := testArgon2idParams
+	slower.Time++
+	lessMemory := testArgon2idParams
+	lessMemory.Memory /= 2
+	longerKey := testArgon2idParams
+	longerKey.KeyLen *= 2
+
+	cases := []struct {
+		name     string
+		hash     string
+		verifier *Hasher
+		rehash   bool
+	}{
+		{"bcrypt of the preferred cost", mustHash(t, bcryptHasher, testPassword), bcryptHasher, false},
+		{"bcrypt of another cost", mustHash(t, mustHasher(t, AlgBcrypt, bcrypt.MinCost+1, testArgon2idParams), testPassword), bcryptHasher, true},
+		{"bcrypt under an argon2id preference", mustHash(t, bcryptHasher, testPassword), argonHasher, true},
+		{"argon2id of the preferred params", mustHash(t, argonHasher, testPassword), argonHasher, false},
+		{"argon2id of another time", mustHash(t, mustHasher(t, AlgArgon2id, bcrypt.MinCost, slower), testPassword), argonHasher, true},
+		{"argon2id of another memory", mustHash(t, mustHasher(t, AlgArgon2id, bcrypt.MinCost, lessMemory), testPassword), argonHasher, true},
+		{"argon2id of another key length", mustHash(t, mustHasher(t, AlgArgon2id, bcrypt.MinCost, longerKey), testPassword), argonHasher, true},
+		{"argon2id under a bcrypt preference", mustHash(t, argonHasher, testPassword), bcryptHasher, true},
+	}
+
+	for _, c := range cases {
+		rehash, err := c.verifier.Verify(c.hash, testPassword)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		} else if rehash != c.rehash {
+			t.Errorf("%s: got rehash %t, want %t", c.name, rehash, c.rehash)
+		}
+
+		rehash, err = c.verifier.Verify(c.hash, testPassword+"x")
+		if !errors.Is(err, ErrMismatch) || rehash {
+			t.Errorf("%s: a wrong password got rehash %t and %v, want %v", c.name, rehash, err, ErrMismatch)
+		}
+	}
+}
+
+func TestHasherVerifyUnknownHash(t *testing.T) {
+	h := mustHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2idParams)
+
+	for _, hash := range []string{"", "not-a-hash", "$1$salt$md5", "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5"} {
+		rehash, err := h.Verify(hash, testPassword)
+		if !errors.Is(err, ErrUnknownHash) || rehash {
+			t.Errorf("%q: got rehash %t and %v, want %v", hash, rehash, err, ErrUnknownHash)
+		}
+	}
+}
+
+func TestDecodeArgon2id(t *testing.T) {
+	valid := mustHash(t, mustHasher(t, AlgArgon2id, bcrypt.MinCost, testArgon2idParams), testPassword)
+	params, salt, key, err := decodeArgon2id(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Time != testArgon2idParams.Time || params.Memory != testArgon2idParams.Memory || params.Threads != testArgon2idParams.Threads ||
+		uint32(len(salt)) != testArgon2idParams.SaltLen || uint32(len(key)) != testArgon2idParams.KeyLen {
+		t.Errorf("%s decoded to %+v with a salt of %d and a key of %d bytes", valid, params, len(salt), len(key))
+	}
+
+	malformed := []string{
+		"",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5$extra",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$t=1,m=64,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$not base64!$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$not base64!",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$",
+	}
+	a := &Argon2id{Params: testArgon2idParams}
+	for _, hash := range malformed {
+		_, _, _, err := decodeArgon2id(hash)
+		if !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("%q: got %v, want %v", hash, err, ErrUnknownHash)
+		}
+		if !a.Outdated(hash) {
+			t.Errorf("%q is not outdated", hash)
+		}
+	}
+}
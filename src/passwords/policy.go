@@ -0,0 +1,110 @@
+package passwords
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+//go:embed breached.txt
+var defaultBreached string
+
+// Policy holding the rules that every new password must pass
+type Policy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	breached      map[string]bool
+}
+
+// NewPolicy use for creating a Policy; breachedPath is a file with one breached password per line
+// and when it is empty a small built-in list of the most common passwords is used
+func NewPolicy(minLength int, upper, lower, digit, symbol bool, breachedPath string) (*Policy, error) {
+	p := &Policy{
+		MinLength:     minLength,
+		RequireUpper:  upper,
+		RequireLower:  lower,
+		RequireDigit:  digit,
+		RequireSymbol: symbol,
+	}
+
+	var err error
+	if breachedPath == "" {
+		p.breached, err = readBreached(strings.NewReader(defaultBreached))
+		return p, err
+	}
+
+	file, err := os.Open(breachedPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	p.breached, err = readBreached(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func readBreached(r io.Reader) (map[string]bool, error) {
+	breached := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		breached[strings.ToLower(line)] = true
+	}
+
+	return breached, scanner.Err()
+}
+
+// Check use for finding every rule of the Policy that the password violates
+func (p *Policy) Check(password string) []string {
+	var violations []string
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters", p.MinLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "password must contain an upper case letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "password must contain a lower case letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "password must contain a symbol")
+	}
+
+	if p.breached[strings.ToLower(password)] {
+		violations = append(violations, "password is in the list of breached passwords")
+	}
+
+	return violations
+}
@@ -0,0 +1,196 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	zerolog "github.com/rs/zerolog/log"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyColumns = `id,user_id,name,prefix,scopes,created_at,last_used_at,expires_at,revoked_at`
+)
+
+// ApiKeyOps holding the operations of the api keys
+type ApiKeyOps interface {
+	AddApiKey(ctx context.Context, key *models.ApiKey, keyHash string) error
+	GetApiKeysByUser(ctx context.Context, userID int) ([]*models.ApiKey, error)
+	GetApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error)
+	TouchApiKey(ctx context.Context, keyID int) error
+	RevokeApiKey(ctx context.Context, keyID, userID int) error
+}
+
+// AddApiKey use for storing a new api key with the sha256 hash of its key
+func (d *DBHolder) AddApiKey(ctx context.Context, key *models.ApiKey, keyHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	query := `INSERT INTO api_keys (user_id,name,prefix,key_hash,scopes,created_at,expires_at) VALUES (?,?,?,?,?,?,?) RETURNING id`
+	err = d.db().QueryRowContext(ctx, d.rebind(query),
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		keyHash,
+		strings.Join(key.Scopes, ","),
+		key.CreatedAt,
+		key.ExpiresAt,
+	).Scan(&key.ID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// GetApiKeysByUser use for listing every api key of a user
+func (d *DBHolder) GetApiKeysByUser(ctx context.Context, userID int) ([]*models.ApiKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.List)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id=? ORDER BY id`
+	results, err := d.db().QueryContext(ctx, d.rebind(query), userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+	defer func(results *sql.Rows) {
+		err := results.Close()
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+			return
+		}
+	}(results)
+
+	var keys []*models.ApiKey = []*models.ApiKey{}
+	for results.Next() {
+		key, err := scanApiKey(results)
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, results.Err()
+}
+
+// GetApiKeyByHash use for finding an api key by the sha256 hash of its key
+func (d *DBHolder) GetApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Read)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash=?`
+	key, err := scanApiKey(d.db().QueryRowContext(ctx, d.rebind(query), keyHash))
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// TouchApiKey use for recording the last time an api key used
+func (d *DBHolder) TouchApiKey(ctx context.Context, keyID int) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	query := `UPDATE api_keys SET last_used_at=? WHERE id=?`
+	_, err := d.db().ExecContext(ctx, d.rebind(query), time.Now().UTC(), keyID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// RevokeApiKey use for revoking an api key of a user
+func (d *DBHolder) RevokeApiKey(ctx context.Context, keyID, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	query := `UPDATE api_keys SET revoked_at=? WHERE id=? AND user_id=? AND revoked_at IS NULL`
+	result, err := d.db().ExecContext(ctx, d.rebind(query), time.Now().UTC(), keyID, userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+	if affected == 0 {
+		return &Error{Kind: ErrNotFound, Field: "id", Message: fmt.Sprintf("there is no active api key with this id=%d", keyID)}
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanApiKey(row rowScanner) (*models.ApiKey, error) {
+	key := &models.ApiKey{}
+	var scopes string
+	var lastUsed, expires, revoked sql.NullTime
+	err := row.Scan(&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&scopes,
+		&key.CreatedAt,
+		&lastUsed,
+		&expires,
+		&revoked,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if expires.Valid {
+		key.ExpiresAt = &expires.Time
+	}
+	if revoked.Valid {
+		key.RevokedAt = &revoked.Time
+	}
+
+	return key, nil
+}
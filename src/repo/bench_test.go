@@ -0,0 +1,189 @@
+package repo
+
+import (
+	"context"
+	"github.com/DapperBlondie/users-cars-systems/src/migrations"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// openBenchDB opens a sqlite database in a temporary directory with its migrations applied and its
+// statements prepared
+func openBenchDB(b *testing.B) *DBHolder {
+	ctx := context.Background()
+
+	d, err := NewDriver(filepath.Join(b.TempDir(), "bench.db"), DefaultDriverOptions)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = d.Dispose() })
+
+	m, err := migrations.New(d.DB, d.Driver)
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = m.Up(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = d.PrepareStatements(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return d
+}
+
+// seedUsers inserts count users in one transaction; the user i has i%3 cars
+func seedUsers(b *testing.B, d *DBHolder, count int) {
+	ctx := context.Background()
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	car := 0
+	for i := 0; i < count; i++ {
+		var id int
+		err = tx.QueryRowContext(ctx, `INSERT INTO users (com_name, sex, birthday, password, role) VALUES (?, ?, ?, ?, ?) RETURNING id`,
+			"Bench User", i%2 == 0, "1990-01-01", "not-a-hash", models.RoleOwner).Scan(&id)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for j := 0; j < i%3; j++ {
+			car++
+			_, err = tx.ExecContext(ctx, `INSERT INTO cars (number_plate, color, vin, owner_id) VALUES (?, ?, ?, ?)`,
+				"P"+strconv.Itoa(car), "red", "VIN"+strconv.Itoa(car), id)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkGetUserPrepared reads a user by the statement that PrepareStatements prepared
+func BenchmarkGetUserPrepared(b *testing.B) {
+	d := openBenchDB(b)
+	seedUsers(b, d, 1000)
+	ctx := context.Background()
+
+	stmt, err := d.stmt(getUserStmt)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		user := &models.Users{}
+		err = stmt.QueryRowContext(ctx, i%1000+1).Scan(&user.ID, &user.CompleteName, &user.Sex, &user.BirthDay, &user.Role)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetUserInline reads a user by the same query that sqlite parses and plans on every call
+func BenchmarkGetUserInline(b *testing.B) {
+	d := openBenchDB(b)
+	seedUsers(b, d, 1000)
+	ctx := context.Background()
+	query := statementQueries[getUserStmt]
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		user := &models.Users{}
+		err := d.DB.QueryRowContext(ctx, query, i%1000+1).Scan(&user.ID, &user.CompleteName, &user.Sex, &user.BirthDay, &user.Role)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// getAllUsersPerUser reads a page the way GetAllUsers did before the join; the ids of the page are read
+// and every user is read by GetUserByID
+func getAllUsersPerUser(ctx context.Context, d *DBHolder, limit, offset int) ([]*models.Users, error) {
+	results, err := d.DB.QueryContext(ctx, `SELECT id FROM users ORDER BY id LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for results.Next() {
+		var id int
+		err = results.Scan(&id)
+		if err != nil {
+			_ = results.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = results.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*models.Users, 0, len(ids))
+	for _, id := range ids {
+		user, err := d.GetUserByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// BenchmarkGetAllUsers compares the one query of GetAllUsers with a query per user over 10k users
+// that have 0 to 2 cars each
+func BenchmarkGetAllUsers(b *testing.B) {
+	d := openBenchDB(b)
+	seedUsers(b, d, 10000)
+	ctx := context.Background()
+
+	pages := []struct {
+		name          string
+		limit, offset int
+	}{
+		{"10000", 10000, 0},
+		{"100-offset-5000", 100, 5000},
+	}
+	ways := []struct {
+		name string
+		get  func(ctx context.Context, limit, offset int) ([]*models.Users, error)
+	}{
+		{"one-query", d.GetAllUsers},
+		{"query-per-user", func(ctx context.Context, limit, offset int) ([]*models.Users, error) {
+			return getAllUsersPerUser(ctx, d, limit, offset)
+		}},
+	}
+
+	for _, page := range pages {
+		for _, way := range ways {
+			page, way := page, way
+			b.Run(page.name+"/"+way.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					users, err := way.get(ctx, page.limit, page.offset)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if len(users) != page.limit {
+						b.Fatalf("got %d users, want %d", len(users), page.limit)
+					}
+				}
+			})
+		}
+	}
+}
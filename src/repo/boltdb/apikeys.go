@@ -0,0 +1,173 @@
+package boltdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+type apiKeyRow struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"key_hash"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AddApiKey use for storing a new api key with the sha256 hash of its key
+func (s *Store) AddApiKey(ctx context.Context, key *models.ApiKey, keyHash string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if !userExists(tx, key.UserID) {
+			return repo.ErrForeignKey
+		}
+		if lookup(tx.Bucket(apiKeysByHash), keyHash) != 0 {
+			return repo.UniqueError("api_keys.key_hash")
+		}
+
+		id, err := nextID(tx.Bucket(apiKeysBucket))
+		if err != nil {
+			return err
+		}
+
+		key.ID = id
+		return putApiKey(tx, apiKeyRow{
+			ID:        id,
+			UserID:    key.UserID,
+			Name:      key.Name,
+			Prefix:    key.Prefix,
+			Hash:      keyHash,
+			Scopes:    append([]string{}, key.Scopes...),
+			CreatedAt: key.CreatedAt,
+			ExpiresAt: key.ExpiresAt,
+		})
+	})
+}
+
+// GetApiKeysByUser use for listing every api key of a user
+func (s *Store) GetApiKeysByUser(ctx context.Context, userID int) ([]*models.ApiKey, error) {
+	var keys []*models.ApiKey = []*models.ApiKey{}
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		for _, id := range idsByPrefix(tx.Bucket(apiKeysByUser), itob(userID)) {
+			var row apiKeyRow
+			_, err := get(tx.Bucket(apiKeysBucket), itob(id), &row)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, row.model())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// GetApiKeyByHash use for finding an api key by the sha256 hash of its key
+func (s *Store) GetApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error) {
+	var key *models.ApiKey
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		var row apiKeyRow
+		ok, err := get(tx.Bucket(apiKeysBucket), itob(lookup(tx.Bucket(apiKeysByHash), keyHash)), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		key = row.model()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// TouchApiKey use for recording the last time an api key used
+func (s *Store) TouchApiKey(ctx context.Context, keyID int) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		var row apiKeyRow
+		ok, err := get(tx.Bucket(apiKeysBucket), itob(keyID), &row)
+		if err != nil || !ok {
+			return err
+		}
+
+		row.LastUsedAt = timePtr(time.Now().UTC())
+		return put(tx.Bucket(apiKeysBucket), itob(keyID), row)
+	})
+}
+
+// RevokeApiKey use for revoking an api key of a user
+func (s *Store) RevokeApiKey(ctx context.Context, keyID, userID int) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		var row apiKeyRow
+		ok, err := get(tx.Bucket(apiKeysBucket), itob(keyID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok || row.UserID != userID || row.RevokedAt != nil {
+			return &repo.Error{Kind: repo.ErrNotFound, Field: "id", Message: fmt.Sprintf("there is no active api key with this id=%d", keyID)}
+		}
+
+		row.RevokedAt = timePtr(time.Now().UTC())
+		return put(tx.Bucket(apiKeysBucket), itob(keyID), row)
+	})
+}
+
+// putApiKey use for storing a new api key with its indexes
+func putApiKey(tx *bolt.Tx, row apiKeyRow) error {
+	err := put(tx.Bucket(apiKeysBucket), itob(row.ID), row)
+	if err == nil {
+		err = tx.Bucket(apiKeysByHash).Put([]byte(row.Hash), itob(row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(apiKeysByUser).Put(pairKey(row.UserID, row.ID), []byte{})
+	}
+
+	return err
+}
+
+func deleteApiKey(tx *bolt.Tx, keyID int) error {
+	var row apiKeyRow
+	ok, err := get(tx.Bucket(apiKeysBucket), itob(keyID), &row)
+	if err != nil || !ok {
+		return err
+	}
+
+	err = tx.Bucket(apiKeysByHash).Delete([]byte(row.Hash))
+	if err == nil {
+		err = tx.Bucket(apiKeysByUser).Delete(pairKey(row.UserID, row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(apiKeysBucket).Delete(itob(keyID))
+	}
+
+	return err
+}
+
+func (r apiKeyRow) model() *models.ApiKey {
+	return &models.ApiKey{
+		ID:         r.ID,
+		UserID:     r.UserID,
+		Name:       r.Name,
+		Prefix:     r.Prefix,
+		Scopes:     append([]string{}, r.Scopes...),
+		CreatedAt:  r.CreatedAt,
+		LastUsedAt: r.LastUsedAt,
+		ExpiresAt:  r.ExpiresAt,
+		RevokedAt:  r.RevokedAt,
+	}
+}
@@ -0,0 +1,261 @@
+package boltdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	bolt "go.etcd.io/bbolt"
+	"strings"
+	"time"
+)
+
+// ImportCounts holding the number of rows that ImportSQL copied from every table
+type ImportCounts map[string]int
+
+// ImportSQL use for copying every table of a migrated sqlite database into the empty Store with the same ids;
+// everything is written in one transaction, so a failure leaves the Store empty
+func (s *Store) ImportSQL(ctx context.Context, db *sql.DB) (ImportCounts, error) {
+	counts := ImportCounts{}
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(usersBucket).Cursor().First(); k != nil {
+			return errors.New("the bolt database already has users; convert into a new file")
+		}
+
+		steps := []struct {
+			table string
+			copy  func(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error)
+		}{
+			{"users", importUsers},
+			{"cars", importCars},
+			{"api_keys", importApiKeys},
+			{"refresh_tokens", importRefreshTokens},
+			{"password_resets", importPasswordResets},
+			{"user_totp", importTOTP},
+			{"recovery_codes", importRecoveryCodes},
+			{"auth_failures", importAuthFailures},
+			{"sessions", importSessions},
+		}
+		for _, step := range steps {
+			n, err := step.copy(ctx, db, tx)
+			if err != nil {
+				return errors.New(step.table + ": " + err.Error())
+			}
+			counts[step.table] = n
+		}
+
+		return importSequences(ctx, db, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// importRows use for running query and calling scan for every row; the bucket sequence is set to the
+// greatest id that scan returns, so new rows continue after the copied ids like autoincrement
+func importRows(ctx context.Context, db *sql.DB, b *bolt.Bucket, query string, scan func(rows *sql.Rows) (int, error)) (int, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n, maxID := 0, 0
+	for rows.Next() {
+		id, err := scan(rows)
+		if err != nil {
+			return 0, err
+		}
+		if id > maxID {
+			maxID = id
+		}
+		n++
+	}
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	if b != nil && uint64(maxID) > b.Sequence() {
+		err = b.SetSequence(uint64(maxID))
+	}
+
+	return n, err
+}
+
+func importUsers(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error) {
+	users := tx.Bucket(usersBucket)
+	query := `SELECT id, com_name, sex, birthday, password, role FROM users`
+	return importRows(ctx, db, users, query, func(rows *sql.Rows) (int, error) {
+		var row userRow
+		err := rows.Scan(&row.ID, &row.CompleteName, &row.Sex, &row.BirthDay, &row.Password, &row.Role)
+		if err != nil {
+			return 0, err
+		}
+
+		return row.ID, put(users, itob(row.ID), row)
+	})
+}
+
+func importCars(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error) {
+	query := `SELECT id, number_plate, color, vin, owner_id FROM cars`
+	return importRows(ctx, db, tx.Bucket(carsBucket), query, func(rows *sql.Rows) (int, error) {
+		var row carRow
+		err := rows.Scan(&row.ID, &row.NumberPlate, &row.Color, &row.VIN, &row.OwnerID)
+		if err != nil {
+			return 0, err
+		}
+
+		return row.ID, putCar(tx, nil, row)
+	})
+}
+
+func importApiKeys(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error) {
+	query := `SELECT id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, expires_at, revoked_at FROM api_keys`
+	return importRows(ctx, db, tx.Bucket(apiKeysBucket), query, func(rows *sql.Rows) (int, error) {
+		var row apiKeyRow
+		var scopes string
+		var lastUsed, expires, revoked sql.NullTime
+		err := rows.Scan(&row.ID, &row.UserID, &row.Name, &row.Prefix, &row.Hash, &scopes, &row.CreatedAt,
+			&lastUsed, &expires, &revoked)
+		if err != nil {
+			return 0, err
+		}
+
+		row.Scopes = []string{}
+		if scopes != "" {
+			row.Scopes = strings.Split(scopes, ",")
+		}
+		row.LastUsedAt, row.ExpiresAt, row.RevokedAt = nullTime(lastUsed), nullTime(expires), nullTime(revoked)
+
+		return row.ID, putApiKey(tx, row)
+	})
+}
+
+func importRefreshTokens(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error) {
+	query := `SELECT id, user_id, token_hash, family_id, mfa, created_at, expires_at, used_at, revoked_at FROM refresh_tokens`
+	return importRows(ctx, db, tx.Bucket(refreshTokensBucket), query, func(rows *sql.Rows) (int, error) {
+		var row refreshTokenRow
+		var used, revoked sql.NullTime
+		err := rows.Scan(&row.ID, &row.UserID, &row.Hash, &row.FamilyID, &row.MFA, &row.CreatedAt, &row.ExpiresAt,
+			&used, &revoked)
+		if err != nil {
+			return 0, err
+		}
+		row.UsedAt, row.RevokedAt = nullTime(used), nullTime(revoked)
+
+		return row.ID, putRefreshToken(tx, row)
+	})
+}
+
+func importPasswordResets(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error) {
+	query := `SELECT id, user_id, token_hash, created_at, expires_at, used_at FROM password_resets`
+	return importRows(ctx, db, tx.Bucket(passwordResetsBucket), query, func(rows *sql.Rows) (int, error) {
+		var row passwordResetRow
+		var used sql.NullTime
+		err := rows.Scan(&row.ID, &row.UserID, &row.Hash, &row.CreatedAt, &row.ExpiresAt, &used)
+		if err != nil {
+			return 0, err
+		}
+		row.UsedAt = nullTime(used)
+
+		return row.ID, putPasswordReset(tx, row)
+	})
+}
+
+func importTOTP(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error) {
+	totps := tx.Bucket(totpBucket)
+	query := `SELECT user_id, secret, created_at, confirmed_at, last_step FROM user_totp`
+	return importRows(ctx, db, nil, query, func(rows *sql.Rows) (int, error) {
+		var row totpRow
+		var confirmed sql.NullTime
+		err := rows.Scan(&row.UserID, &row.Secret, &row.CreatedAt, &confirmed, &row.LastStep)
+		if err != nil {
+			return 0, err
+		}
+		row.ConfirmedAt = nullTime(confirmed)
+
+		return row.UserID, put(totps, itob(row.UserID), row)
+	})
+}
+
+func importRecoveryCodes(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error) {
+	query := `SELECT id, user_id, code_hash, used_at FROM recovery_codes`
+	return importRows(ctx, db, tx.Bucket(recoveryCodesBucket), query, func(rows *sql.Rows) (int, error) {
+		var row recoveryCodeRow
+		var used sql.NullTime
+		err := rows.Scan(&row.ID, &row.UserID, &row.Hash, &used)
+		if err != nil {
+			return 0, err
+		}
+		row.UsedAt = nullTime(used)
+
+		return row.ID, putRecoveryCode(tx, row)
+	})
+}
+
+func importAuthFailures(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error) {
+	failures := tx.Bucket(authFailuresBucket)
+	query := `SELECT kind, subject, failures, last_failure, locked_until FROM auth_failures`
+	return importRows(ctx, db, nil, query, func(rows *sql.Rows) (int, error) {
+		var row authFailureRow
+		var locked sql.NullTime
+		err := rows.Scan(&row.Kind, &row.Subject, &row.Failures, &row.LastFailure, &locked)
+		if err != nil {
+			return 0, err
+		}
+		row.LockedUntil = nullTime(locked)
+
+		return 0, put(failures, failureKey(row.Kind, row.Subject), row)
+	})
+}
+
+func importSessions(ctx context.Context, db *sql.DB, tx *bolt.Tx) (int, error) {
+	sessions := tx.Bucket(sessionsBucket)
+	query := `SELECT token, data, expiry FROM sessions`
+	return importRows(ctx, db, nil, query, func(rows *sql.Rows) (int, error) {
+		var token string
+		var data []byte
+		var expiry int64
+		err := rows.Scan(&token, &data, &expiry)
+		if err != nil {
+			return 0, err
+		}
+
+		v := make([]byte, 8, 8+len(data))
+		binary.BigEndian.PutUint64(v, uint64(expiry))
+		return 0, sessions.Put([]byte(token), append(v, data...))
+	})
+}
+
+// importSequences use for continuing the ids of every bucket after the last autoincrement id of its table,
+// so the ids of deleted rows are not used again
+func importSequences(ctx context.Context, db *sql.DB, tx *bolt.Tx) error {
+	query := `SELECT name, seq FROM sqlite_sequence`
+	_, err := importRows(ctx, db, nil, query, func(rows *sql.Rows) (int, error) {
+		var name string
+		var seq int
+		err := rows.Scan(&name, &seq)
+		if err != nil {
+			return 0, err
+		}
+
+		b := tx.Bucket([]byte(name))
+		if b == nil || uint64(seq) <= b.Sequence() {
+			return 0, nil
+		}
+		return 0, b.SetSequence(uint64(seq))
+	})
+
+	return err
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return timePtr(t.Time)
+}
@@ -0,0 +1,116 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	bolt "go.etcd.io/bbolt"
+	"sort"
+	"time"
+)
+
+type authFailureRow struct {
+	Kind        string     `json:"kind"`
+	Subject     string     `json:"subject"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+func failureKey(kind, subject string) []byte {
+	return []byte(kind + "\x00" + subject)
+}
+
+// GetAuthFailure use for getting the failed attempts of a user or an ip; nil means no failure is recorded
+func (s *Store) GetAuthFailure(ctx context.Context, kind, subject string) (*models.AuthFailure, error) {
+	var f *models.AuthFailure
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		var row authFailureRow
+		ok, err := get(tx.Bucket(authFailuresBucket), failureKey(kind, subject), &row)
+		if ok {
+			f = row.model()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// RecordAuthFailure use for counting a failed attempt; the count restarts when the last failure is older
+// than window and the subject is not locked, and the subject is locked for lockFor when it reaches lockAfter failures
+func (s *Store) RecordAuthFailure(ctx context.Context, kind, subject string, lockAfter int, lockFor, window time.Duration) (*models.AuthFailure, error) {
+	var f *models.AuthFailure
+	err := s.update(ctx, func(tx *bolt.Tx) error {
+		failures := tx.Bucket(authFailuresBucket)
+		now := time.Now().UTC()
+
+		var row authFailureRow
+		ok, err := get(failures, failureKey(kind, subject), &row)
+		if err != nil {
+			return err
+		}
+		if !ok || (now.Sub(row.LastFailure) > window && !row.model().LockedAt(now)) {
+			row = authFailureRow{Kind: kind, Subject: subject}
+		}
+
+		row.Failures++
+		row.LastFailure = now
+		if row.Failures >= lockAfter {
+			row.LockedUntil = timePtr(now.Add(lockFor))
+		}
+
+		f = row.model()
+		return put(failures, failureKey(kind, subject), row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ResetAuthFailures use for removing the failed attempts and the lockout of a user or an ip
+func (s *Store) ResetAuthFailures(ctx context.Context, kind, subject string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return tx.Bucket(authFailuresBucket).Delete(failureKey(kind, subject))
+	})
+}
+
+// GetAuthFailures use for listing every user and ip that has failed attempts
+func (s *Store) GetAuthFailures(ctx context.Context) ([]*models.AuthFailure, error) {
+	var failures []*models.AuthFailure = []*models.AuthFailure{}
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		return tx.Bucket(authFailuresBucket).ForEach(func(k, v []byte) error {
+			var row authFailureRow
+			err := json.Unmarshal(v, &row)
+			if err != nil {
+				return err
+			}
+
+			failures = append(failures, row.model())
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].LastFailure.After(failures[j].LastFailure)
+	})
+
+	return failures, nil
+}
+
+func (r authFailureRow) model() *models.AuthFailure {
+	return &models.AuthFailure{
+		Kind:        r.Kind,
+		Subject:     r.Subject,
+		Failures:    r.Failures,
+		LastFailure: r.LastFailure,
+		LockedUntil: r.LockedUntil,
+	}
+}
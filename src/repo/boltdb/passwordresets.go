@@ -0,0 +1,119 @@
+package boltdb
+
+import (
+	"context"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+type passwordResetRow struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Hash      string     `json:"token_hash"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// AddPasswordReset use for storing a reset token hash of a user; older unused tokens of the user become invalid
+func (s *Store) AddPasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if !userExists(tx, userID) {
+			return repo.ErrForeignKey
+		}
+		if lookup(tx.Bucket(passwordResetsByHash), tokenHash) != 0 {
+			return repo.UniqueError("password_resets.token_hash")
+		}
+
+		resets := tx.Bucket(passwordResetsBucket)
+		now := time.Now().UTC()
+		for _, id := range idsByPrefix(tx.Bucket(passwordResetsByUser), itob(userID)) {
+			var row passwordResetRow
+			_, err := get(resets, itob(id), &row)
+			if err != nil {
+				return err
+			}
+			if row.UsedAt != nil {
+				continue
+			}
+
+			row.UsedAt = timePtr(now)
+			err = put(resets, itob(id), row)
+			if err != nil {
+				return err
+			}
+		}
+
+		id, err := nextID(resets)
+		if err != nil {
+			return err
+		}
+
+		return putPasswordReset(tx, passwordResetRow{
+			ID:        id,
+			UserID:    userID,
+			Hash:      tokenHash,
+			CreatedAt: now,
+			ExpiresAt: expiresAt.UTC(),
+		})
+	})
+}
+
+// ConsumePasswordReset use for marking a reset token as used and returning its user; a token can be consumed once
+func (s *Store) ConsumePasswordReset(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	err := s.update(ctx, func(tx *bolt.Tx) error {
+		resets := tx.Bucket(passwordResetsBucket)
+		id := lookup(tx.Bucket(passwordResetsByHash), tokenHash)
+
+		var row passwordResetRow
+		ok, err := get(resets, itob(id), &row)
+		if err != nil {
+			return err
+		}
+		if !ok || row.UsedAt != nil || time.Now().After(row.ExpiresAt) {
+			return repo.ErrInvalidResetToken
+		}
+
+		row.UsedAt = timePtr(time.Now().UTC())
+		userID = row.UserID
+		return put(resets, itob(id), row)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// putPasswordReset use for storing a new reset token with its indexes
+func putPasswordReset(tx *bolt.Tx, row passwordResetRow) error {
+	err := put(tx.Bucket(passwordResetsBucket), itob(row.ID), row)
+	if err == nil {
+		err = tx.Bucket(passwordResetsByHash).Put([]byte(row.Hash), itob(row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(passwordResetsByUser).Put(pairKey(row.UserID, row.ID), []byte{})
+	}
+
+	return err
+}
+
+func deletePasswordReset(tx *bolt.Tx, resetID int) error {
+	var row passwordResetRow
+	ok, err := get(tx.Bucket(passwordResetsBucket), itob(resetID), &row)
+	if err != nil || !ok {
+		return err
+	}
+
+	err = tx.Bucket(passwordResetsByHash).Delete([]byte(row.Hash))
+	if err == nil {
+		err = tx.Bucket(passwordResetsByUser).Delete(pairKey(row.UserID, row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(passwordResetsBucket).Delete(itob(resetID))
+	}
+
+	return err
+}
@@ -0,0 +1,177 @@
+package boltdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+type refreshTokenRow struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Hash      string     `json:"token_hash"`
+	FamilyID  string     `json:"family_id"`
+	MFA       bool       `json:"mfa"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AddRefreshToken use for storing a refresh token by the sha256 hash of its token
+func (s *Store) AddRefreshToken(ctx context.Context, token *models.RefreshToken, tokenHash string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if !userExists(tx, token.UserID) {
+			return repo.ErrForeignKey
+		}
+		if lookup(tx.Bucket(refreshTokensByHash), tokenHash) != 0 {
+			return repo.UniqueError("refresh_tokens.token_hash")
+		}
+
+		id, err := nextID(tx.Bucket(refreshTokensBucket))
+		if err != nil {
+			return err
+		}
+
+		token.ID = id
+		return putRefreshToken(tx, refreshTokenRow{
+			ID:        id,
+			UserID:    token.UserID,
+			Hash:      tokenHash,
+			FamilyID:  token.FamilyID,
+			MFA:       token.MFA,
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+		})
+	})
+}
+
+// GetRefreshTokenByHash use for finding a refresh token by the sha256 hash of its token
+func (s *Store) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token *models.RefreshToken
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		var row refreshTokenRow
+		ok, err := get(tx.Bucket(refreshTokensBucket), itob(lookup(tx.Bucket(refreshTokensByHash), tokenHash)), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		token = row.model()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// UseRefreshToken use for marking a refresh token as rotated; only one caller can use a token
+func (s *Store) UseRefreshToken(ctx context.Context, tokenID int) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		var row refreshTokenRow
+		ok, err := get(tx.Bucket(refreshTokensBucket), itob(tokenID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok || row.UsedAt != nil {
+			return repo.ErrRefreshTokenUsed
+		}
+
+		row.UsedAt = timePtr(time.Now().UTC())
+		return put(tx.Bucket(refreshTokensBucket), itob(tokenID), row)
+	})
+}
+
+// RevokeRefreshTokenFamily use for revoking a refresh token with every token that rotated from the same login
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return revokeRefreshTokens(tx, idsByPrefix(tx.Bucket(refreshTokensByFamily), []byte(familyID+"\x00")))
+	})
+}
+
+// RevokeUserRefreshTokens use for revoking every refresh token of a user
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return revokeRefreshTokens(tx, idsByPrefix(tx.Bucket(refreshTokensByUser), itob(userID)))
+	})
+}
+
+func revokeRefreshTokens(tx *bolt.Tx, ids []int) error {
+	tokens := tx.Bucket(refreshTokensBucket)
+	now := time.Now().UTC()
+	for _, id := range ids {
+		var row refreshTokenRow
+		_, err := get(tokens, itob(id), &row)
+		if err != nil {
+			return err
+		}
+		if row.RevokedAt != nil {
+			continue
+		}
+
+		row.RevokedAt = timePtr(now)
+		err = put(tokens, itob(id), row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putRefreshToken use for storing a new refresh token with its indexes
+func putRefreshToken(tx *bolt.Tx, row refreshTokenRow) error {
+	err := put(tx.Bucket(refreshTokensBucket), itob(row.ID), row)
+	if err == nil {
+		err = tx.Bucket(refreshTokensByHash).Put([]byte(row.Hash), itob(row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(refreshTokensByUser).Put(pairKey(row.UserID, row.ID), []byte{})
+	}
+	if err == nil {
+		err = tx.Bucket(refreshTokensByFamily).Put(familyKey(row.FamilyID, row.ID), []byte{})
+	}
+
+	return err
+}
+
+func deleteRefreshToken(tx *bolt.Tx, tokenID int) error {
+	var row refreshTokenRow
+	ok, err := get(tx.Bucket(refreshTokensBucket), itob(tokenID), &row)
+	if err != nil || !ok {
+		return err
+	}
+
+	err = tx.Bucket(refreshTokensByHash).Delete([]byte(row.Hash))
+	if err == nil {
+		err = tx.Bucket(refreshTokensByUser).Delete(pairKey(row.UserID, row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(refreshTokensByFamily).Delete(familyKey(row.FamilyID, row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(refreshTokensBucket).Delete(itob(tokenID))
+	}
+
+	return err
+}
+
+func (r refreshTokenRow) model() *models.RefreshToken {
+	return &models.RefreshToken{
+		ID:        r.ID,
+		UserID:    r.UserID,
+		FamilyID:  r.FamilyID,
+		MFA:       r.MFA,
+		CreatedAt: r.CreatedAt,
+		ExpiresAt: r.ExpiresAt,
+		UsedAt:    r.UsedAt,
+		RevokedAt: r.RevokedAt,
+	}
+}
@@ -0,0 +1,158 @@
+package boltdb
+
+import (
+	"encoding/binary"
+	zerolog "github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// SessionStore is a scs.Store that keeps the sessions in the sessions bucket; a value is the expiry in
+// unix nanoseconds followed by the data of the session
+type SessionStore struct {
+	Store       *Store
+	stopCleanup chan bool
+	doneCleanup chan bool
+}
+
+// NewSessionStore use for creating a SessionStore; if cleanupInterval is greater than zero
+// a background job removes the expired sessions every cleanupInterval until StopCleanup is called
+func (s *Store) NewSessionStore(cleanupInterval time.Duration) *SessionStore {
+	store := &SessionStore{
+		Store: s,
+	}
+
+	if cleanupInterval > 0 {
+		store.stopCleanup = make(chan bool)
+		store.doneCleanup = make(chan bool)
+		go store.startCleanup(cleanupInterval)
+	}
+
+	return store
+}
+
+// Find use for getting the data of a session token that is not expired
+func (ss *SessionStore) Find(token string) ([]byte, bool, error) {
+	var data []byte
+	err := ss.Store.DB.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(sessionsBucket).Get([]byte(token))
+		if v == nil || int64(binary.BigEndian.Uint64(v)) <= time.Now().UnixNano() {
+			return nil
+		}
+
+		data = append([]byte{}, v[8:]...)
+		return nil
+	})
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, false, err
+	}
+
+	return data, data != nil, nil
+}
+
+// Commit use for adding a session token or replacing its data and expiry
+func (ss *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	v := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint64(v, uint64(expiry.UnixNano()))
+	v = append(v, b...)
+
+	err := ss.Store.DB.Batch(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(token), v)
+	})
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// Delete use for removing a session token
+func (ss *SessionStore) Delete(token string) error {
+	err := ss.Store.DB.Batch(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(token))
+	})
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// All use for getting the data of every session that is not expired by its token
+func (ss *SessionStore) All() (map[string][]byte, error) {
+	sessions := map[string][]byte{}
+	err := ss.Store.DB.View(func(tx *bolt.Tx) error {
+		now := time.Now().UnixNano()
+		return tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+			if int64(binary.BigEndian.Uint64(v)) > now {
+				sessions[string(k)] = append([]byte{}, v[8:]...)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// deleteExpired use for removing every session that its expiry passed
+func (ss *SessionStore) deleteExpired() error {
+	return ss.Store.DB.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(sessionsBucket)
+		now := time.Now().UnixNano()
+
+		var expired [][]byte
+		err := sessions.ForEach(func(k, v []byte) error {
+			if int64(binary.BigEndian.Uint64(v)) <= now {
+				expired = append(expired, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			err = sessions.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ss *SessionStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer close(ss.doneCleanup)
+
+	for {
+		select {
+		case <-ticker.C:
+			err := ss.deleteExpired()
+			if err != nil {
+				zerolog.Error().Msg(err.Error())
+			}
+		case <-ss.stopCleanup:
+			return
+		}
+	}
+}
+
+// StopCleanup use for terminating the background cleanup job and waiting for it
+func (ss *SessionStore) StopCleanup() {
+	if ss.stopCleanup == nil {
+		return
+	}
+
+	close(ss.stopCleanup)
+	<-ss.doneCleanup
+	ss.stopCleanup = nil
+}
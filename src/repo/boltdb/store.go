@@ -0,0 +1,247 @@
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// Buckets of the tables; every row is stored as json under the big endian bytes of its id
+var (
+	usersBucket          = []byte("users")
+	carsBucket           = []byte("cars")
+	apiKeysBucket        = []byte("api_keys")
+	refreshTokensBucket  = []byte("refresh_tokens")
+	passwordResetsBucket = []byte("password_resets")
+	totpBucket           = []byte("user_totp")
+	recoveryCodesBucket  = []byte("recovery_codes")
+	authFailuresBucket   = []byte("auth_failures")
+	sessionsBucket       = []byte("sessions")
+)
+
+// Buckets of the secondary indexes; a unique index maps its value to the id of the row and the key of
+// an index by user is the id of the user followed by the id of the row with an empty value
+var (
+	carsByVIN             = []byte("cars_by_vin")
+	carsByPlate           = []byte("cars_by_plate")
+	carsByOwner           = []byte("cars_by_owner")
+	apiKeysByHash         = []byte("api_keys_by_hash")
+	apiKeysByUser         = []byte("api_keys_by_user")
+	refreshTokensByHash   = []byte("refresh_tokens_by_hash")
+	refreshTokensByUser   = []byte("refresh_tokens_by_user")
+	refreshTokensByFamily = []byte("refresh_tokens_by_family")
+	passwordResetsByHash  = []byte("password_resets_by_hash")
+	passwordResetsByUser  = []byte("password_resets_by_user")
+	recoveryCodesByHash   = []byte("recovery_codes_by_hash")
+	recoveryCodesByUser   = []byte("recovery_codes_by_user")
+)
+
+var buckets = [][]byte{
+	usersBucket, carsBucket, apiKeysBucket, refreshTokensBucket, passwordResetsBucket, totpBucket,
+	recoveryCodesBucket, authFailuresBucket, sessionsBucket,
+	carsByVIN, carsByPlate, carsByOwner, apiKeysByHash, apiKeysByUser, refreshTokensByHash, refreshTokensByUser,
+	refreshTokensByFamily, passwordResetsByHash, passwordResetsByUser, recoveryCodesByHash, recoveryCodesByUser,
+}
+
+var _ repo.ApiOpsInterface = (*Store)(nil)
+
+// Store is an ApiOpsInterface that keeps everything in a bbolt file, so it needs no cgo; it has the
+// constraints of the sqlite schema: unique vin, number plate and hashes, the owner of a row must exist
+// and deleting a user deletes its cars, keys, tokens and two factor data
+type Store struct {
+	DB *bolt.DB
+	// tx is set on the Store that WithTx passes to its function
+	tx *bolt.Tx
+}
+
+// Open use for opening the bbolt file of path with every bucket created; it waits a second for another
+// process that holds the file
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Store{DB: db}, nil
+}
+
+// Close use for closing the bbolt file
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
+
+// WithTx use for running fn in a read-write transaction; it is committed when fn returns nil and rolled back
+// when fn returns an error or panics. Calling WithTx in fn joins the same transaction
+func (s *Store) WithTx(ctx context.Context, fn func(tx repo.Repo) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return fn(&Store{DB: s.DB, tx: tx})
+	})
+}
+
+// view use for running fn in a read-only transaction
+func (s *Store) view(ctx context.Context, fn func(tx *bolt.Tx) error) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	return s.DB.View(fn)
+}
+
+// update use for running fn in a read-write transaction; the writes of concurrent callers are committed
+// together in one batch, so fn can run more than once and should only change the buckets
+func (s *Store) update(ctx context.Context, fn func(tx *bolt.Tx) error) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	return s.DB.Batch(fn)
+}
+
+// nextID use for getting the next id of a bucket like an autoincrement column
+func nextID(b *bolt.Bucket) (int, error) {
+	id, err := b.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func itob(v int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+func btoi(b []byte) int {
+	return int(binary.BigEndian.Uint64(b))
+}
+
+// pairKey use for making the key of an index by user
+func pairKey(userID, id int) []byte {
+	return append(itob(userID), itob(id)...)
+}
+
+// familyKey use for making the key of the refresh tokens by family index; a family id has no zero byte
+func familyKey(familyID string, id int) []byte {
+	return append([]byte(familyID+"\x00"), itob(id)...)
+}
+
+// idsByPrefix use for getting the ids of the rows in an index with a key that starts with prefix;
+// the id is the last eight bytes of the key
+func idsByPrefix(b *bolt.Bucket, prefix []byte) []int {
+	var ids []int
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		ids = append(ids, btoi(k[len(k)-8:]))
+	}
+
+	return ids
+}
+
+// lookup use for getting the id of a row by a unique index; zero means no row has the value
+func lookup(b *bolt.Bucket, value string) int {
+	v := b.Get([]byte(value))
+	if v == nil {
+		return 0
+	}
+
+	return btoi(v)
+}
+
+// get use for decoding the row of key into row; false means there is no row
+func get(b *bolt.Bucket, key []byte, row interface{}) (bool, error) {
+	v := b.Get(key)
+	if v == nil {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(v, row)
+}
+
+func put(b *bolt.Bucket, key []byte, row interface{}) error {
+	v, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(key, v)
+}
+
+func userExists(tx *bolt.Tx, userID int) bool {
+	return tx.Bucket(usersBucket).Get(itob(userID)) != nil
+}
+
+// deleteUser use for deleting a user with every row that refers to it like ON DELETE CASCADE
+func deleteUser(tx *bolt.Tx, userID int) error {
+	for _, id := range idsByPrefix(tx.Bucket(carsByOwner), itob(userID)) {
+		err := deleteCar(tx, id)
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range idsByPrefix(tx.Bucket(apiKeysByUser), itob(userID)) {
+		err := deleteApiKey(tx, id)
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range idsByPrefix(tx.Bucket(refreshTokensByUser), itob(userID)) {
+		err := deleteRefreshToken(tx, id)
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range idsByPrefix(tx.Bucket(passwordResetsByUser), itob(userID)) {
+		err := deletePasswordReset(tx, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := deleteTOTP(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(usersBucket).Delete(itob(userID))
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
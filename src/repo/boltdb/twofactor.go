@@ -0,0 +1,223 @@
+package boltdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+type totpRow struct {
+	UserID      int        `json:"user_id"`
+	Secret      string     `json:"secret"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	LastStep    int64      `json:"last_step"`
+}
+
+type recoveryCodeRow struct {
+	ID     int        `json:"id"`
+	UserID int        `json:"user_id"`
+	Hash   string     `json:"code_hash"`
+	UsedAt *time.Time `json:"used_at,omitempty"`
+}
+
+// SetTOTPSecret use for storing a new unconfirmed totp secret of a user
+func (s *Store) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if !userExists(tx, userID) {
+			return repo.ErrForeignKey
+		}
+
+		var row totpRow
+		ok, err := get(tx.Bucket(totpBucket), itob(userID), &row)
+		if err != nil {
+			return err
+		}
+		if ok && row.ConfirmedAt != nil {
+			return repo.ErrTOTPEnabled
+		}
+
+		return put(tx.Bucket(totpBucket), itob(userID), totpRow{UserID: userID, Secret: secret, CreatedAt: time.Now().UTC()})
+	})
+}
+
+// GetTOTP use for getting the totp enrollment of a user; sql.ErrNoRows means the user never enrolled
+func (s *Store) GetTOTP(ctx context.Context, userID int) (*models.TOTP, error) {
+	var totp *models.TOTP
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		var row totpRow
+		ok, err := get(tx.Bucket(totpBucket), itob(userID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		totp = &models.TOTP{
+			UserID:    userID,
+			Secret:    row.Secret,
+			Confirmed: row.ConfirmedAt != nil,
+			LastStep:  row.LastStep,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return totp, nil
+}
+
+// ConfirmTOTP use for enabling the totp of a user and replacing its recovery codes
+func (s *Store) ConfirmTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		var row totpRow
+		ok, err := get(tx.Bucket(totpBucket), itob(userID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok || row.ConfirmedAt != nil {
+			return repo.ErrTOTPEnabled
+		}
+
+		err = replaceRecoveryCodes(tx, userID, codeHashes)
+		if err != nil {
+			return err
+		}
+
+		row.ConfirmedAt = timePtr(time.Now().UTC())
+		row.LastStep = step
+		return put(tx.Bucket(totpBucket), itob(userID), row)
+	})
+}
+
+// ReplaceRecoveryCodes use for generating a new set of recovery codes; the old ones become invalid
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// replaceRecoveryCodes use for deleting the old codes of the user and storing the new codes; a failed check
+// rolls back the deletes with the transaction
+func replaceRecoveryCodes(tx *bolt.Tx, userID int, codeHashes []string) error {
+	if len(codeHashes) > 0 && !userExists(tx, userID) {
+		return repo.ErrForeignKey
+	}
+
+	err := deleteRecoveryCodes(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	codes := tx.Bucket(recoveryCodesBucket)
+	for _, h := range codeHashes {
+		if lookup(tx.Bucket(recoveryCodesByHash), h) != 0 {
+			return repo.UniqueError("recovery_codes.code_hash")
+		}
+
+		id, err := nextID(codes)
+		if err != nil {
+			return err
+		}
+
+		err = putRecoveryCode(tx, recoveryCodeRow{ID: id, UserID: userID, Hash: h})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseTOTPStep use for recording the time step of an accepted code; older or equal steps are rejected
+func (s *Store) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		var row totpRow
+		ok, err := get(tx.Bucket(totpBucket), itob(userID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok || row.LastStep >= step {
+			return repo.ErrTOTPReplayed
+		}
+
+		row.LastStep = step
+		return put(tx.Bucket(totpBucket), itob(userID), row)
+	})
+}
+
+// UseRecoveryCode use for consuming a recovery code of a user
+func (s *Store) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		codes := tx.Bucket(recoveryCodesBucket)
+		id := lookup(tx.Bucket(recoveryCodesByHash), codeHash)
+
+		var row recoveryCodeRow
+		ok, err := get(codes, itob(id), &row)
+		if err != nil {
+			return err
+		}
+		if !ok || row.UserID != userID || row.UsedAt != nil {
+			return repo.ErrRecoveryCodeInvalid
+		}
+
+		row.UsedAt = timePtr(time.Now().UTC())
+		return put(codes, itob(id), row)
+	})
+}
+
+// DisableTOTP use for removing the totp and recovery codes of a user
+func (s *Store) DisableTOTP(ctx context.Context, userID int) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		return deleteTOTP(tx, userID)
+	})
+}
+
+func deleteTOTP(tx *bolt.Tx, userID int) error {
+	err := deleteRecoveryCodes(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(totpBucket).Delete(itob(userID))
+}
+
+// putRecoveryCode use for storing a new recovery code with its indexes
+func putRecoveryCode(tx *bolt.Tx, row recoveryCodeRow) error {
+	err := put(tx.Bucket(recoveryCodesBucket), itob(row.ID), row)
+	if err == nil {
+		err = tx.Bucket(recoveryCodesByHash).Put([]byte(row.Hash), itob(row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(recoveryCodesByUser).Put(pairKey(row.UserID, row.ID), []byte{})
+	}
+
+	return err
+}
+
+func deleteRecoveryCodes(tx *bolt.Tx, userID int) error {
+	codes := tx.Bucket(recoveryCodesBucket)
+	for _, id := range idsByPrefix(tx.Bucket(recoveryCodesByUser), itob(userID)) {
+		var row recoveryCodeRow
+		_, err := get(codes, itob(id), &row)
+		if err == nil {
+			err = tx.Bucket(recoveryCodesByHash).Delete([]byte(row.Hash))
+		}
+		if err == nil {
+			err = tx.Bucket(recoveryCodesByUser).Delete(pairKey(userID, id))
+		}
+		if err == nil {
+			err = codes.Delete(itob(id))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
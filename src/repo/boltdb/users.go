@@ -0,0 +1,480 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// userRow is the stored user; it has its own json names, so the api json of models.Users can change
+// without changing the file
+type userRow struct {
+	ID           int         `json:"id"`
+	CompleteName string      `json:"com_name"`
+	Sex          bool        `json:"sex"`
+	BirthDay     string      `json:"birthday"`
+	Password     string      `json:"password"`
+	Role         models.Role `json:"role"`
+}
+
+type carRow struct {
+	ID          int    `json:"id"`
+	NumberPlate string `json:"number_plate"`
+	Color       string `json:"color"`
+	VIN         string `json:"vin"`
+	OwnerID     int    `json:"owner_id"`
+}
+
+// AddUser use for adding a user; its id is set like the autoincrement id of sqlite
+func (s *Store) AddUser(ctx context.Context, user *models.Users) error {
+	_, err := time.Parse(repo.BirthDayLayout, user.BirthDay)
+	if err != nil {
+		return repo.Invalid("birth_day", "birth_day is not a date like "+repo.BirthDayLayout)
+	}
+
+	if user.Role == "" {
+		user.Role = models.RoleOwner
+	}
+
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		id, err := nextID(users)
+		if err != nil {
+			return err
+		}
+
+		user.ID = id
+		return put(users, itob(id), userRow{
+			ID:           id,
+			CompleteName: user.CompleteName,
+			Sex:          user.Sex,
+			BirthDay:     user.BirthDay,
+			Password:     user.Password,
+			Role:         user.Role,
+		})
+	})
+}
+
+// DeleteUser use for deleting a user with its cars, keys, tokens and two factor data
+func (s *Store) DeleteUser(ctx context.Context, userID int) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if !userExists(tx, userID) {
+			return repo.NotFound("user")
+		}
+
+		return deleteUser(tx, userID)
+	})
+}
+
+// DeleteCar use for deleting a car by its id with its index entries
+func (s *Store) DeleteCar(ctx context.Context, carID int) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if tx.Bucket(carsBucket).Get(itob(carID)) == nil {
+			return repo.NotFound("car")
+		}
+
+		return deleteCar(tx, carID)
+	})
+}
+
+// AddCar use for adding a car of an existing user; its vin and number plate must be unique
+func (s *Store) AddCar(ctx context.Context, car *models.Cars) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if !userExists(tx, car.OwnerID) {
+			return repo.ForeignKeyError("owner_id", fmt.Sprintf("there is no user with this id=%d", car.OwnerID))
+		}
+
+		err := checkCar(tx, 0, car)
+		if err != nil {
+			return err
+		}
+
+		id, err := nextID(tx.Bucket(carsBucket))
+		if err != nil {
+			return err
+		}
+
+		car.ID = id
+		return putCar(tx, nil, carRow{ID: id, NumberPlate: car.NumberPlate, Color: car.Color, VIN: car.VIN, OwnerID: car.OwnerID})
+	})
+}
+
+// GetUserByID use for getting a user with its cars; repo.ErrNotFound means the user does not exist
+func (s *Store) GetUserByID(ctx context.Context, userID int) (*models.Users, error) {
+	var user *models.Users
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		var row userRow
+		ok, err := get(tx.Bucket(usersBucket), itob(userID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return repo.NotFound("user")
+		}
+
+		user, err = userWithCars(tx, row)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetAllUsers use for getting a page of users in the order of their ids with their cars
+func (s *Store) GetAllUsers(ctx context.Context, limit, offset int) ([]*models.Users, error) {
+	var users []*models.Users
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		c := tx.Bucket(usersBucket).Cursor()
+		skipped := 0
+		// like sqlite a negative offset is no offset and a negative limit is no limit
+		for k, v := c.First(); k != nil && (limit < 0 || len(users) < limit); k, v = c.Next() {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+
+			var row userRow
+			err := json.Unmarshal(v, &row)
+			if err != nil {
+				return err
+			}
+
+			user, err := userWithCars(tx, row)
+			if err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// UpdateUser use for update the profile of a user; the password is changed by UpdateUserPassword
+func (s *Store) UpdateUser(ctx context.Context, user *models.Users) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if !userExists(tx, user.ID) {
+			return repo.NotFound("user")
+		}
+
+		return changeUser(tx, user.ID, func(row *userRow) {
+			row.CompleteName = user.CompleteName
+			row.Sex = user.Sex
+			row.BirthDay = user.BirthDay
+		})
+	})
+}
+
+// UpdateCar use for update a car by its id; its new vin and number plate must be unique
+func (s *Store) UpdateCar(ctx context.Context, car *models.Cars) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		var row carRow
+		ok, err := get(tx.Bucket(carsBucket), itob(car.ID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return repo.NotFound("car")
+		}
+
+		err = checkCar(tx, car.ID, car)
+		if err != nil {
+			return err
+		}
+
+		old := row
+		row.NumberPlate = car.NumberPlate
+		row.Color = car.Color
+		row.VIN = car.VIN
+
+		return putCar(tx, &old, row)
+	})
+}
+
+// PatchUser use for changing only the fields of a user that patch has; it returns the updated user with its cars
+func (s *Store) PatchUser(ctx context.Context, userID int, patch *models.UserPatch) (*models.Users, error) {
+	var user *models.Users
+	err := s.update(ctx, func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+
+		var row userRow
+		ok, err := get(users, itob(userID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return repo.NotFound("user")
+		}
+
+		if patch.CompleteName != nil {
+			row.CompleteName = *patch.CompleteName
+		}
+		if patch.Sex != nil {
+			row.Sex = *patch.Sex
+		}
+		if patch.BirthDay != nil {
+			row.BirthDay = *patch.BirthDay
+		}
+
+		err = put(users, itob(userID), row)
+		if err != nil {
+			return err
+		}
+
+		user, err = userWithCars(tx, row)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// PatchCar use for changing only the fields of a car that patch has; its new vin and number plate must be unique
+func (s *Store) PatchCar(ctx context.Context, carID int, patch *models.CarPatch) (*models.Cars, error) {
+	var car *models.Cars
+	err := s.update(ctx, func(tx *bolt.Tx) error {
+		var row carRow
+		ok, err := get(tx.Bucket(carsBucket), itob(carID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return repo.NotFound("car")
+		}
+
+		old := row
+		if patch.NumberPlate != nil {
+			row.NumberPlate = *patch.NumberPlate
+		}
+		if patch.Color != nil {
+			row.Color = *patch.Color
+		}
+		if patch.VIN != nil {
+			row.VIN = *patch.VIN
+		}
+
+		err = checkCar(tx, carID, row.model())
+		if err != nil {
+			return err
+		}
+
+		car = row.model()
+		return putCar(tx, &old, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return car, nil
+}
+
+// GetUserPassword use for getting the stored password hash of a user for authentication
+func (s *Store) GetUserPassword(ctx context.Context, userID int) (string, error) {
+	var password string
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		var row userRow
+		ok, err := get(tx.Bucket(usersBucket), itob(userID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return repo.NoUser(userID)
+		}
+
+		password = row.Password
+		return nil
+	})
+
+	return password, err
+}
+
+// UpdateUserPassword use for replacing the password hash of a user
+func (s *Store) UpdateUserPassword(ctx context.Context, userID int, password string) error {
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if !userExists(tx, userID) {
+			return repo.NoUser(userID)
+		}
+
+		return changeUser(tx, userID, func(row *userRow) {
+			row.Password = password
+		})
+	})
+}
+
+// GetUserRole use for getting the role of a user for authorization
+func (s *Store) GetUserRole(ctx context.Context, userID int) (models.Role, error) {
+	var role models.Role
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		var row userRow
+		ok, err := get(tx.Bucket(usersBucket), itob(userID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return repo.NoUser(userID)
+		}
+
+		role = row.Role
+		return nil
+	})
+
+	return role, err
+}
+
+// SetUserRole use for changing the role of a user
+func (s *Store) SetUserRole(ctx context.Context, userID int, role models.Role) error {
+	if !role.Valid() {
+		return repo.Invalid("role", fmt.Sprintf("%s is not a valid role", role))
+	}
+
+	return s.update(ctx, func(tx *bolt.Tx) error {
+		if !userExists(tx, userID) {
+			return repo.NoUser(userID)
+		}
+
+		return changeUser(tx, userID, func(row *userRow) {
+			row.Role = role
+		})
+	})
+}
+
+// GetCarByID use for getting a car by its id
+func (s *Store) GetCarByID(ctx context.Context, carID int) (*models.Cars, error) {
+	var car *models.Cars
+	err := s.view(ctx, func(tx *bolt.Tx) error {
+		var row carRow
+		ok, err := get(tx.Bucket(carsBucket), itob(carID), &row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return repo.NotFound("car")
+		}
+
+		car = row.model()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return car, nil
+}
+
+// changeUser use for changing the stored row of a user by change; a user that does not exist is not an error
+func changeUser(tx *bolt.Tx, userID int, change func(row *userRow)) error {
+	users := tx.Bucket(usersBucket)
+
+	var row userRow
+	ok, err := get(users, itob(userID), &row)
+	if err != nil || !ok {
+		return err
+	}
+
+	change(&row)
+	return put(users, itob(userID), row)
+}
+
+// checkCar use for checking no car other than carID has the vin or the number plate of car
+func checkCar(tx *bolt.Tx, carID int, car *models.Cars) error {
+	id := lookup(tx.Bucket(carsByVIN), car.VIN)
+	if id != 0 && id != carID {
+		return repo.UniqueError("cars.vin")
+	}
+
+	id = lookup(tx.Bucket(carsByPlate), car.NumberPlate)
+	if id != 0 && id != carID {
+		return repo.UniqueError("cars.number_plate")
+	}
+
+	return nil
+}
+
+// putCar use for storing a car with its indexes; old is the stored car that row replaces
+func putCar(tx *bolt.Tx, old *carRow, row carRow) error {
+	if old != nil {
+		err := tx.Bucket(carsByVIN).Delete([]byte(old.VIN))
+		if err == nil {
+			err = tx.Bucket(carsByPlate).Delete([]byte(old.NumberPlate))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err := put(tx.Bucket(carsBucket), itob(row.ID), row)
+	if err == nil {
+		err = tx.Bucket(carsByVIN).Put([]byte(row.VIN), itob(row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(carsByPlate).Put([]byte(row.NumberPlate), itob(row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(carsByOwner).Put(pairKey(row.OwnerID, row.ID), []byte{})
+	}
+
+	return err
+}
+
+// deleteCar use for deleting a car with its indexes
+func deleteCar(tx *bolt.Tx, carID int) error {
+	var row carRow
+	ok, err := get(tx.Bucket(carsBucket), itob(carID), &row)
+	if err != nil || !ok {
+		return err
+	}
+
+	err = tx.Bucket(carsByVIN).Delete([]byte(row.VIN))
+	if err == nil {
+		err = tx.Bucket(carsByPlate).Delete([]byte(row.NumberPlate))
+	}
+	if err == nil {
+		err = tx.Bucket(carsByOwner).Delete(pairKey(row.OwnerID, row.ID))
+	}
+	if err == nil {
+		err = tx.Bucket(carsBucket).Delete(itob(carID))
+	}
+
+	return err
+}
+
+// userWithCars use for making the user of row with its cars in the order of their ids; the password is not
+// copied like the sqlite backend does not read it with the profile
+func userWithCars(tx *bolt.Tx, row userRow) (*models.Users, error) {
+	user := &models.Users{
+		ID:           row.ID,
+		CompleteName: row.CompleteName,
+		Sex:          row.Sex,
+		BirthDay:     row.BirthDay,
+		Role:         row.Role,
+		UsersCars:    []*models.Cars{},
+	}
+
+	cars := tx.Bucket(carsBucket)
+	for _, id := range idsByPrefix(tx.Bucket(carsByOwner), itob(row.ID)) {
+		var car carRow
+		_, err := get(cars, itob(id), &car)
+		if err != nil {
+			return nil, err
+		}
+		user.UsersCars = append(user.UsersCars, car.model())
+	}
+
+	return user, nil
+}
+
+func (r carRow) model() *models.Cars {
+	return &models.Cars{ID: r.ID, NumberPlate: r.NumberPlate, Color: r.Color, VIN: r.VIN, OwnerID: r.OwnerID}
+}
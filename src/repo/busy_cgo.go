@@ -0,0 +1,36 @@
+//go:build cgo
+// +build cgo
+
+package repo
+
+import (
+	"errors"
+	"github.com/mattn/go-sqlite3"
+)
+
+// isSQLiteBusy use for checking an error of sqlite is returned because another connection holds the lock
+func isSQLiteBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+
+	return false
+}
+
+// sqliteConstraint use for getting the constraint that an error of sqlite violated
+func sqliteConstraint(err error) int {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return constraintNone
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return constraintUnique
+	case sqlite3.ErrConstraintForeignKey:
+		return constraintForeignKey
+	}
+
+	return constraintNone
+}
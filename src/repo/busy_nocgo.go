@@ -0,0 +1,14 @@
+//go:build !cgo
+// +build !cgo
+
+package repo
+
+// isSQLiteBusy use for builds without cgo; they have no sqlite, so an error is never returned by it
+func isSQLiteBusy(err error) bool {
+	return false
+}
+
+// sqliteConstraint use for builds without cgo; there is no sqlite error to classify
+func sqliteConstraint(err error) int {
+	return constraintNone
+}
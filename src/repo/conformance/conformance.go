@@ -0,0 +1,826 @@
+package conformance
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"time"
+)
+
+// Case is a behaviour that every ApiOpsInterface backend must have
+type Case struct {
+	Name string
+	Run  func(ctx context.Context, r repo.ApiOpsInterface) error
+}
+
+// Failure holding a case that failed with the reason
+type Failure struct {
+	Case string
+	Err  error
+}
+
+// Open use for creating an empty backend for one case; close releases it after the case
+type Open func() (r repo.ApiOpsInterface, close func(), err error)
+
+// Run use for running every case against its own empty backend; it returns the failed cases
+func Run(ctx context.Context, open Open) []*Failure {
+	var failures []*Failure
+	for _, c := range Cases {
+		r, closeBackend, err := open()
+		if err != nil {
+			failures = append(failures, &Failure{Case: c.Name, Err: err})
+			continue
+		}
+
+		err = c.Run(ctx, r)
+		closeBackend()
+		if err != nil {
+			failures = append(failures, &Failure{Case: c.Name, Err: err})
+		}
+	}
+
+	return failures
+}
+
+// Cases holding the behaviours that the sqlite backend has and the other backends must copy
+var Cases = []Case{
+	{"add and get a user", addAndGetUser},
+	{"unknown rows are sql.ErrNoRows", unknownRows},
+	{"add and get cars", addAndGetCars},
+	{"vin is unique", uniqueVIN},
+	{"number plate is unique", uniqueNumberPlate},
+	{"updated car stays unique", updateCarUnique},
+	{"patch changes only its fields", patchFields},
+	{"owner must exist", ownerMustExist},
+	{"deleting a user deletes its rows", deleteUserCascades},
+	{"deleting a car frees its vin and number plate", deleteCar},
+	{"users are paged in the order of their ids", pageUsers},
+	{"transaction commits or rolls back", transactions},
+	{"update user, password and role", updateUser},
+	{"refresh token is used once", refreshTokenUsedOnce},
+	{"password reset is consumed once", passwordResetConsumedOnce},
+	{"totp steps and recovery codes are used once", totpUsedOnce},
+	{"failed attempts lock a subject", authFailuresLock},
+	{"errors have their domain kinds", domainErrors},
+}
+
+func addUser(ctx context.Context, r repo.ApiOpsInterface, name string) (*models.Users, error) {
+	user := &models.Users{CompleteName: name, Sex: true, BirthDay: "2000-01-02", Password: "hash-of-" + name}
+	err := r.AddUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("AddUser did not set the id of the user")
+	}
+
+	return user, nil
+}
+
+func addCar(ctx context.Context, r repo.ApiOpsInterface, ownerID int, plate, vin string) (*models.Cars, error) {
+	car := &models.Cars{NumberPlate: plate, Color: "red", VIN: vin, OwnerID: ownerID}
+	err := r.AddCar(ctx, car)
+	if err != nil {
+		return nil, err
+	}
+	if car.ID == 0 {
+		return nil, errors.New("AddCar did not set the id of the car")
+	}
+
+	return car, nil
+}
+
+// expect use for making the error of a case when cond is false
+func expect(cond bool, format string, args ...interface{}) error {
+	if cond {
+		return nil
+	}
+
+	return fmt.Errorf(format, args...)
+}
+
+func countUsers(ctx context.Context, r repo.ApiOpsInterface) (int, error) {
+	users, err := r.GetAllUsers(ctx, -1, 0)
+	return len(users), err
+}
+
+func addAndGetUser(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "first user")
+	if err != nil {
+		return err
+	}
+
+	got, err := r.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = expect(got.ID == user.ID && got.CompleteName == user.CompleteName && got.Sex == user.Sex && got.BirthDay == user.BirthDay,
+		"GetUserByID returned %+v for %+v", got, user)
+	if err != nil {
+		return err
+	}
+	err = expect(got.Role == models.RoleOwner, "a new user has the role %q, not owner", got.Role)
+	if err != nil {
+		return err
+	}
+	err = expect(got.Password == "" && got.UsersCars != nil && len(got.UsersCars) == 0,
+		"a new user must have no password in its profile and an empty list of cars")
+	if err != nil {
+		return err
+	}
+
+	password, err := r.GetUserPassword(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	return expect(password == user.Password, "GetUserPassword returned %q, not %q", password, user.Password)
+}
+
+func unknownRows(ctx context.Context, r repo.ApiOpsInterface) error {
+	_, err := r.GetUserByID(ctx, 999)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("GetUserByID of an unknown user returned %v", err)
+	}
+
+	_, err = r.GetCarByID(ctx, 999)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("GetCarByID of an unknown car returned %v", err)
+	}
+
+	_, err = r.GetUserPassword(ctx, 999)
+	if !errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("GetUserPassword of an unknown user returned %v", err)
+	}
+
+	_, err = r.GetUserRole(ctx, 999)
+	if !errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("GetUserRole of an unknown user returned %v", err)
+	}
+
+	_, err = r.GetTOTP(ctx, 999)
+	return expect(errors.Is(err, sql.ErrNoRows), "GetTOTP of an unknown user returned %v", err)
+}
+
+func addAndGetCars(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "car owner")
+	if err != nil {
+		return err
+	}
+	first, err := addCar(ctx, r, user.ID, "P-1", "VIN-1")
+	if err != nil {
+		return err
+	}
+	second, err := addCar(ctx, r, user.ID, "P-2", "VIN-2")
+	if err != nil {
+		return err
+	}
+
+	got, err := r.GetCarByID(ctx, second.ID)
+	if err != nil {
+		return err
+	}
+	err = expect(*got == *second, "GetCarByID returned %+v for %+v", got, second)
+	if err != nil {
+		return err
+	}
+
+	owner, err := r.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = expect(len(owner.UsersCars) == 2, "the user has %d cars, not 2", len(owner.UsersCars))
+	if err != nil {
+		return err
+	}
+
+	return expect(*owner.UsersCars[0] == *first && *owner.UsersCars[1] == *second,
+		"the cars of the user are %+v and %+v", owner.UsersCars[0], owner.UsersCars[1])
+}
+
+func uniqueVIN(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "car owner")
+	if err != nil {
+		return err
+	}
+	_, err = addCar(ctx, r, user.ID, "P-1", "VIN-1")
+	if err != nil {
+		return err
+	}
+
+	_, err = addCar(ctx, r, user.ID, "P-2", "VIN-1")
+	err = expect(err != nil, "a second car with the same vin is added")
+	if err != nil {
+		return err
+	}
+
+	owner, err := r.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	return expect(len(owner.UsersCars) == 1, "the rejected car is stored")
+}
+
+func uniqueNumberPlate(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "car owner")
+	if err != nil {
+		return err
+	}
+	_, err = addCar(ctx, r, user.ID, "P-1", "VIN-1")
+	if err != nil {
+		return err
+	}
+
+	_, err = addCar(ctx, r, user.ID, "P-1", "VIN-2")
+	return expect(err != nil, "a second car with the same number plate is added")
+}
+
+func updateCarUnique(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "car owner")
+	if err != nil {
+		return err
+	}
+	first, err := addCar(ctx, r, user.ID, "P-1", "VIN-1")
+	if err != nil {
+		return err
+	}
+	second, err := addCar(ctx, r, user.ID, "P-2", "VIN-2")
+	if err != nil {
+		return err
+	}
+
+	err = r.UpdateCar(ctx, &models.Cars{ID: second.ID, NumberPlate: "P-2", Color: "blue", VIN: first.VIN})
+	err = expect(err != nil, "a car is updated to the vin of another car")
+	if err != nil {
+		return err
+	}
+
+	err = r.UpdateCar(ctx, &models.Cars{ID: second.ID, NumberPlate: "P-3", Color: "blue", VIN: "VIN-2"})
+	if err != nil {
+		return err
+	}
+	got, err := r.GetCarByID(ctx, second.ID)
+	if err != nil {
+		return err
+	}
+	return expect(got.NumberPlate == "P-3" && got.Color == "blue" && got.OwnerID == user.ID,
+		"the updated car is %+v", got)
+}
+
+func patchFields(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "car owner")
+	if err != nil {
+		return err
+	}
+	first, err := addCar(ctx, r, user.ID, "P-1", "VIN-1")
+	if err != nil {
+		return err
+	}
+	second, err := addCar(ctx, r, user.ID, "P-2", "VIN-2")
+	if err != nil {
+		return err
+	}
+
+	color := "blue"
+	got, err := r.PatchCar(ctx, second.ID, &models.CarPatch{Color: &color})
+	if err != nil {
+		return err
+	}
+	err = expect(got.ID == second.ID && got.Color == "blue" && got.NumberPlate == "P-2" && got.VIN == "VIN-2" && got.OwnerID == user.ID,
+		"the patched car is %+v", got)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.PatchCar(ctx, second.ID, &models.CarPatch{VIN: &first.VIN})
+	err = expect(errors.Is(err, repo.ErrConflict), "patching a car to the vin of another car returned %v", err)
+	if err != nil {
+		return err
+	}
+	_, err = r.PatchCar(ctx, 999, &models.CarPatch{Color: &color})
+	err = expect(errors.Is(err, repo.ErrNotFound), "patching an unknown car returned %v", err)
+	if err != nil {
+		return err
+	}
+
+	name := "patched user"
+	patched, err := r.PatchUser(ctx, user.ID, &models.UserPatch{CompleteName: &name})
+	if err != nil {
+		return err
+	}
+	err = expect(patched.CompleteName == name && patched.BirthDay == user.BirthDay && patched.Sex == user.Sex && len(patched.UsersCars) == 2,
+		"the patched user is %+v", patched)
+	if err != nil {
+		return err
+	}
+
+	unchanged, err := r.PatchUser(ctx, user.ID, &models.UserPatch{})
+	if err != nil {
+		return err
+	}
+	err = expect(unchanged.CompleteName == name, "an empty patch changed the user to %+v", unchanged)
+	if err != nil {
+		return err
+	}
+	_, err = r.PatchUser(ctx, 999, &models.UserPatch{})
+	return expect(errors.Is(err, repo.ErrNotFound), "patching an unknown user returned %v", err)
+}
+
+func ownerMustExist(ctx context.Context, r repo.ApiOpsInterface) error {
+	_, err := addCar(ctx, r, 999, "P-1", "VIN-1")
+	err = expect(err != nil, "a car of an unknown user is added")
+	if err != nil {
+		return err
+	}
+
+	key := &models.ApiKey{UserID: 999, Name: "ci", Prefix: "ucs_1", Scopes: []string{}, CreatedAt: time.Now().UTC()}
+	err = r.AddApiKey(ctx, key, "key-hash")
+	return expect(err != nil, "an api key of an unknown user is added")
+}
+
+func deleteUserCascades(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "deleted user")
+	if err != nil {
+		return err
+	}
+	other, err := addUser(ctx, r, "other user")
+	if err != nil {
+		return err
+	}
+	car, err := addCar(ctx, r, user.ID, "P-1", "VIN-1")
+	if err != nil {
+		return err
+	}
+	otherCar, err := addCar(ctx, r, other.ID, "P-2", "VIN-2")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	err = r.AddApiKey(ctx, &models.ApiKey{UserID: user.ID, Name: "ci", Prefix: "ucs_1", Scopes: []string{}, CreatedAt: now}, "key-hash")
+	if err != nil {
+		return err
+	}
+	err = r.AddRefreshToken(ctx, &models.RefreshToken{UserID: user.ID, FamilyID: "family", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}, "token-hash")
+	if err != nil {
+		return err
+	}
+	err = r.SetTOTPSecret(ctx, user.ID, "SECRET")
+	if err != nil {
+		return err
+	}
+
+	err = r.DeleteUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.GetUserByID(ctx, user.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("the deleted user is found: %v", err)
+	}
+	_, err = r.GetCarByID(ctx, car.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("the car of the deleted user is found: %v", err)
+	}
+	_, err = r.GetApiKeyByHash(ctx, "key-hash")
+	if err == nil {
+		return errors.New("the api key of the deleted user is found")
+	}
+	_, err = r.GetRefreshTokenByHash(ctx, "token-hash")
+	if err == nil {
+		return errors.New("the refresh token of the deleted user is found")
+	}
+	_, err = r.GetTOTP(ctx, user.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("the totp of the deleted user is found: %v", err)
+	}
+
+	_, err = r.GetCarByID(ctx, otherCar.ID)
+	return expect(err == nil, "the car of another user is deleted: %v", err)
+}
+
+func deleteCar(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "car owner")
+	if err != nil {
+		return err
+	}
+	car, err := addCar(ctx, r, user.ID, "P-1", "VIN-1")
+	if err != nil {
+		return err
+	}
+	other, err := addCar(ctx, r, user.ID, "P-2", "VIN-2")
+	if err != nil {
+		return err
+	}
+
+	err = r.DeleteCar(ctx, car.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.GetCarByID(ctx, car.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("the deleted car is found: %v", err)
+	}
+	owner, err := r.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = expect(len(owner.UsersCars) == 1 && owner.UsersCars[0].ID == other.ID, "the owner has %+v after the delete", owner.UsersCars)
+	if err != nil {
+		return err
+	}
+
+	_, err = addCar(ctx, r, user.ID, "P-1", "VIN-1")
+	return expect(err == nil, "the vin and number plate of the deleted car are still taken: %v", err)
+}
+
+func pageUsers(ctx context.Context, r repo.ApiOpsInterface) error {
+	var ids []int
+	for i := 0; i < 5; i++ {
+		user, err := addUser(ctx, r, fmt.Sprintf("user %d", i))
+		if err != nil {
+			return err
+		}
+		ids = append(ids, user.ID)
+	}
+	_, err := addCar(ctx, r, ids[2], "P-1", "VIN-1")
+	if err != nil {
+		return err
+	}
+
+	page, err := r.GetAllUsers(ctx, 2, 1)
+	if err != nil {
+		return err
+	}
+	err = expect(len(page) == 2 && page[0].ID == ids[1] && page[1].ID == ids[2], "the page of limit 2 and offset 1 is wrong")
+	if err != nil {
+		return err
+	}
+	err = expect(len(page[0].UsersCars) == 0 && len(page[1].UsersCars) == 1 && page[1].UsersCars[0].OwnerID == ids[2],
+		"the cars of the page are wrong")
+	if err != nil {
+		return err
+	}
+
+	page, err = r.GetAllUsers(ctx, 10, 5)
+	if err != nil {
+		return err
+	}
+	return expect(len(page) == 0, "the page after the last user has %d users", len(page))
+}
+
+func transactions(ctx context.Context, r repo.ApiOpsInterface) error {
+	failed := errors.New("failed on purpose")
+	err := r.WithTx(ctx, func(tx repo.Repo) error {
+		_, err := addUser(ctx, tx, "rolled back")
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		return fmt.Errorf("WithTx returned %v, not the error of its function", err)
+	}
+	count, err := countUsers(ctx, r)
+	if err != nil {
+		return err
+	}
+	err = expect(count == 0, "the user of a rolled back transaction is stored")
+	if err != nil {
+		return err
+	}
+
+	var userID int
+	err = r.WithTx(ctx, func(tx repo.Repo) error {
+		user, err := addUser(ctx, tx, "committed")
+		if err != nil {
+			return err
+		}
+		userID = user.ID
+		_, err = addCar(ctx, tx, user.ID, "P-1", "VIN-1")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	user, err := r.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = expect(len(user.UsersCars) == 1, "the car of a committed transaction is not stored")
+	if err != nil {
+		return err
+	}
+
+	// the other operations of Repo run in the transaction too, so they are rolled back with it
+	err = r.WithTx(ctx, func(tx repo.Repo) error {
+		_, err := tx.RecordAuthFailure(ctx, models.FailureKindUser, "rolled back", 5, time.Minute, time.Minute)
+		if err != nil {
+			return err
+		}
+		err = tx.AddPasswordReset(ctx, userID, "rolled-back-reset", time.Now().Add(time.Hour))
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		return fmt.Errorf("WithTx returned %v, not the error of its function", err)
+	}
+	f, err := r.GetAuthFailure(ctx, models.FailureKindUser, "rolled back")
+	if err == nil && f != nil && f.Failures > 0 {
+		return fmt.Errorf("the failure of a rolled back transaction is stored: %+v", f)
+	}
+	_, err = r.ConsumePasswordReset(ctx, "rolled-back-reset")
+	return expect(errors.Is(err, repo.ErrInvalidResetToken), "the reset of a rolled back transaction is stored")
+}
+
+func updateUser(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "old name")
+	if err != nil {
+		return err
+	}
+
+	err = r.UpdateUser(ctx, &models.Users{ID: user.ID, CompleteName: "new name", Sex: false, BirthDay: user.BirthDay})
+	if err != nil {
+		return err
+	}
+	got, err := r.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = expect(got.CompleteName == "new name" && !got.Sex, "the updated user is %+v", got)
+	if err != nil {
+		return err
+	}
+
+	err = r.UpdateUserPassword(ctx, user.ID, "new hash")
+	if err != nil {
+		return err
+	}
+	password, err := r.GetUserPassword(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = expect(password == "new hash", "the password is %q after UpdateUserPassword", password)
+	if err != nil {
+		return err
+	}
+
+	err = r.SetUserRole(ctx, user.ID, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	role, err := r.GetUserRole(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = expect(role == models.RoleAdmin, "the role is %q after SetUserRole", role)
+	if err != nil {
+		return err
+	}
+
+	err = r.SetUserRole(ctx, user.ID, models.Role("root"))
+	err = expect(err != nil, "an invalid role is set")
+	if err != nil {
+		return err
+	}
+	err = r.SetUserRole(ctx, 999, models.RoleAdmin)
+	err = expect(err != nil, "the role of an unknown user is set")
+	if err != nil {
+		return err
+	}
+
+	err = r.UpdateUserPassword(ctx, 999, "hash")
+	return expect(err != nil, "the password of an unknown user is updated")
+}
+
+func refreshTokenUsedOnce(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "token user")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	token := &models.RefreshToken{UserID: user.ID, FamilyID: "family", MFA: true, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	err = r.AddRefreshToken(ctx, token, "token-hash")
+	if err != nil {
+		return err
+	}
+	err = r.AddRefreshToken(ctx, &models.RefreshToken{UserID: user.ID, FamilyID: "family", CreatedAt: now, ExpiresAt: now}, "token-hash")
+	err = expect(err != nil, "two refresh tokens with the same hash are added")
+	if err != nil {
+		return err
+	}
+
+	err = r.UseRefreshToken(ctx, token.ID)
+	if err != nil {
+		return err
+	}
+	err = r.UseRefreshToken(ctx, token.ID)
+	if !errors.Is(err, repo.ErrRefreshTokenUsed) {
+		return fmt.Errorf("using a refresh token again returned %v", err)
+	}
+
+	err = r.RevokeRefreshTokenFamily(ctx, "family")
+	if err != nil {
+		return err
+	}
+	got, err := r.GetRefreshTokenByHash(ctx, "token-hash")
+	if err != nil {
+		return err
+	}
+	return expect(got.ID == token.ID && got.MFA && got.UsedAt != nil && got.RevokedAt != nil,
+		"the used and revoked token is %+v", got)
+}
+
+func passwordResetConsumedOnce(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "reset user")
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(time.Hour)
+	err = r.AddPasswordReset(ctx, user.ID, "old-reset", expires)
+	if err != nil {
+		return err
+	}
+	err = r.AddPasswordReset(ctx, user.ID, "new-reset", expires)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.ConsumePasswordReset(ctx, "old-reset")
+	if !errors.Is(err, repo.ErrInvalidResetToken) {
+		return fmt.Errorf("an older reset token is consumed: %v", err)
+	}
+
+	userID, err := r.ConsumePasswordReset(ctx, "new-reset")
+	if err != nil {
+		return err
+	}
+	err = expect(userID == user.ID, "the reset token is of user %d, not %d", userID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.ConsumePasswordReset(ctx, "new-reset")
+	return expect(errors.Is(err, repo.ErrInvalidResetToken), "a reset token is consumed twice: %v", err)
+}
+
+func totpUsedOnce(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "totp user")
+	if err != nil {
+		return err
+	}
+
+	err = r.SetTOTPSecret(ctx, user.ID, "SECRET")
+	if err != nil {
+		return err
+	}
+	err = r.ConfirmTOTP(ctx, user.ID, 10, []string{"code-1", "code-2"})
+	if err != nil {
+		return err
+	}
+	err = r.SetTOTPSecret(ctx, user.ID, "OTHER")
+	if !errors.Is(err, repo.ErrTOTPEnabled) {
+		return fmt.Errorf("a confirmed totp is enrolled again: %v", err)
+	}
+
+	t, err := r.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = expect(t.Confirmed && t.Secret == "SECRET" && t.LastStep == 10, "the confirmed totp is %+v", t)
+	if err != nil {
+		return err
+	}
+
+	err = r.UseTOTPStep(ctx, user.ID, 10)
+	if !errors.Is(err, repo.ErrTOTPReplayed) {
+		return fmt.Errorf("a time step is used twice: %v", err)
+	}
+	err = r.UseTOTPStep(ctx, user.ID, 11)
+	if err != nil {
+		return err
+	}
+
+	err = r.UseRecoveryCode(ctx, user.ID, "code-1")
+	if err != nil {
+		return err
+	}
+	err = r.UseRecoveryCode(ctx, user.ID, "code-1")
+	if !errors.Is(err, repo.ErrRecoveryCodeInvalid) {
+		return fmt.Errorf("a recovery code is used twice: %v", err)
+	}
+
+	err = r.DisableTOTP(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	err = r.UseRecoveryCode(ctx, user.ID, "code-2")
+	return expect(errors.Is(err, repo.ErrRecoveryCodeInvalid), "a recovery code works after the totp is disabled: %v", err)
+}
+
+func authFailuresLock(ctx context.Context, r repo.ApiOpsInterface) error {
+	var f *models.AuthFailure
+	var err error
+	for i := 0; i < 3; i++ {
+		f, err = r.RecordAuthFailure(ctx, models.FailureKindIP, "203.0.113.7", 3, time.Minute, time.Hour)
+		if err != nil {
+			return err
+		}
+		if i < 2 && f.LockedUntil != nil {
+			return fmt.Errorf("the subject is locked after %d failures", f.Failures)
+		}
+	}
+	err = expect(f.Failures == 3 && f.LockedUntil != nil, "the subject is not locked after 3 failures: %+v", f)
+	if err != nil {
+		return err
+	}
+
+	// a lockout that is longer than the window keeps its failures and its end
+	time.Sleep(time.Millisecond)
+	f, err = r.RecordAuthFailure(ctx, models.FailureKindIP, "203.0.113.7", 3, time.Minute, time.Nanosecond)
+	if err != nil {
+		return err
+	}
+	err = expect(f.Failures == 4 && f.LockedAt(time.Now()), "a locked subject is reset after the window: %+v", f)
+	if err != nil {
+		return err
+	}
+
+	all, err := r.GetAuthFailures(ctx)
+	if err != nil {
+		return err
+	}
+	err = expect(len(all) == 1 && all[0].Subject == "203.0.113.7", "GetAuthFailures returned %d subjects", len(all))
+	if err != nil {
+		return err
+	}
+
+	err = r.ResetAuthFailures(ctx, models.FailureKindIP, "203.0.113.7")
+	if err != nil {
+		return err
+	}
+	f, err = r.GetAuthFailure(ctx, models.FailureKindIP, "203.0.113.7")
+	if err != nil {
+		return err
+	}
+	return expect(f == nil, "the failures are found after ResetAuthFailures")
+}
+
+func domainErrors(ctx context.Context, r repo.ApiOpsInterface) error {
+	user, err := addUser(ctx, r, "car owner")
+	if err != nil {
+		return err
+	}
+	_, err = addCar(ctx, r, user.ID, "P-1", "VIN-1")
+	if err != nil {
+		return err
+	}
+
+	_, err = addCar(ctx, r, user.ID, "P-2", "VIN-1")
+	var domainErr *repo.Error
+	if !errors.Is(err, repo.ErrConflict) || !errors.As(err, &domainErr) || domainErr.Field != "vin" {
+		return fmt.Errorf("a duplicate vin returned %v", err)
+	}
+	_, err = addCar(ctx, r, user.ID+100, "P-3", "VIN-3")
+	if !errors.Is(err, repo.ErrForeignKey) {
+		return fmt.Errorf("a car of an unknown owner returned %v", err)
+	}
+	_, err = r.GetUserByID(ctx, user.ID+100)
+	if !errors.Is(err, repo.ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("an unknown user returned %v", err)
+	}
+	_, err = r.GetCarByID(ctx, 999)
+	if !errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("an unknown car returned %v", err)
+	}
+	err = r.SetUserRole(ctx, user.ID+100, models.RoleAdmin)
+	if !errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("the role of an unknown user returned %v", err)
+	}
+	err = r.UpdateUser(ctx, &models.Users{ID: user.ID + 100, CompleteName: "nobody", BirthDay: user.BirthDay})
+	if !errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("updating an unknown user returned %v", err)
+	}
+	err = r.UpdateCar(ctx, &models.Cars{ID: 999, NumberPlate: "P-9", Color: "red", VIN: "VIN-9"})
+	if !errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("updating an unknown car returned %v", err)
+	}
+	err = r.DeleteUser(ctx, user.ID+100)
+	if !errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("deleting an unknown user returned %v", err)
+	}
+	err = r.DeleteCar(ctx, 999)
+	if !errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("deleting an unknown car returned %v", err)
+	}
+	err = r.SetUserRole(ctx, user.ID, models.Role("pilot"))
+	return expect(errors.Is(err, repo.ErrValidation), "an invalid role returned %v", err)
+}
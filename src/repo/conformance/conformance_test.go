@@ -0,0 +1,103 @@
+package conformance_test
+
+import (
+	"context"
+	"github.com/DapperBlondie/users-cars-systems/src/migrations"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/boltdb"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/conformance"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/memory"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testTimeouts = repo.Timeouts{Read: 5 * time.Second, Write: 10 * time.Second, List: 10 * time.Second}
+
+// runCases runs every conformance case as a subtest against its own backend of open
+func runCases(t *testing.T, open conformance.Open) {
+	for _, c := range conformance.Cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			r, closeBackend, err := open()
+			if err != nil {
+				t.Fatalf("opening the backend: %v", err)
+			}
+			defer closeBackend()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			err = c.Run(ctx, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// openDBHolder opens the database of dsn by opts with its migrations applied and its statements prepared
+func openDBHolder(ctx context.Context, dsn string, opts repo.DriverOptions) (*repo.DBHolder, *migrations.Migrator, error) {
+	dbh, err := repo.NewDriver(dsn, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	dbh.Timeouts = testTimeouts
+
+	m, err := migrations.New(dbh.DB, dbh.Driver)
+	if err == nil {
+		err = m.Up(ctx)
+	}
+	if err == nil {
+		err = dbh.PrepareStatements(ctx)
+	}
+	if err != nil {
+		_ = dbh.Dispose()
+		return nil, nil, err
+	}
+
+	return dbh, m, nil
+}
+
+func TestMemory(t *testing.T) {
+	runCases(t, func() (repo.ApiOpsInterface, func(), error) {
+		return memory.NewStore(), func() {}, nil
+	})
+}
+
+func TestSQLite(t *testing.T) {
+	runCases(t, func() (repo.ApiOpsInterface, func(), error) {
+		dbh, _, err := openDBHolder(context.Background(), filepath.Join(t.TempDir(), "conformance.db"), repo.DefaultDriverOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+		return dbh, func() { _ = dbh.Dispose() }, nil
+	})
+}
+
+// TestSQLiteOneConnection runs the cases with a pool of one connection; an operation in WithTx that takes
+// another connection of the pool waits for the transaction until its timeout
+func TestSQLiteOneConnection(t *testing.T) {
+	opts := repo.DefaultDriverOptions
+	opts.MaxOpenConns = 1
+	opts.MaxIdleConns = 1
+
+	runCases(t, func() (repo.ApiOpsInterface, func(), error) {
+		dbh, _, err := openDBHolder(context.Background(), filepath.Join(t.TempDir(), "conformance.db"), opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		dbh.Timeouts = repo.Timeouts{Read: time.Second, Write: time.Second, List: time.Second}
+		return dbh, func() { _ = dbh.Dispose() }, nil
+	})
+}
+
+func TestBolt(t *testing.T) {
+	runCases(t, func() (repo.ApiOpsInterface, func(), error) {
+		store, err := boltdb.Open(filepath.Join(t.TempDir(), "conformance.bolt"))
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, func() { _ = store.Close() }, nil
+	})
+}
@@ -0,0 +1,59 @@
+package conformance_test
+
+import (
+	"context"
+	"github.com/DapperBlondie/users-cars-systems/src/migrations"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"os"
+	"testing"
+)
+
+// PostgresDSNEnv names the postgres that TestPostgres runs against; every case rolls back its migrations
+// before and after it, so use a database only for the tests
+const PostgresDSNEnv = "UCS_TEST_POSTGRES_DSN"
+
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skip(PostgresDSNEnv + " is not set, so the postgres backend is not tested; see the PostgreSQL Backend section of README.md")
+	}
+	if repo.DriverFor(dsn) != repo.DriverPostgres {
+		t.Fatalf("%s is not a postgres:// dsn", PostgresDSNEnv)
+	}
+
+	runCases(t, func() (repo.ApiOpsInterface, func(), error) {
+		ctx := context.Background()
+		err := emptyPostgres(ctx, dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		dbh, m, err := openDBHolder(ctx, dsn, repo.DefaultDriverOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+		return dbh, func() {
+			err := m.To(ctx, 0)
+			if err != nil {
+				t.Error(err)
+			}
+			_ = dbh.Dispose()
+		}, nil
+	})
+}
+
+// emptyPostgres rolls back every migration of the postgres of dsn, so a case starts without tables or rows
+func emptyPostgres(ctx context.Context, dsn string) error {
+	dbh, err := repo.NewDriver(dsn, repo.DefaultDriverOptions)
+	if err != nil {
+		return err
+	}
+	defer dbh.Dispose()
+
+	m, err := migrations.New(dbh.DB, dbh.Driver)
+	if err != nil {
+		return err
+	}
+
+	return m.To(ctx, 0)
+}
@@ -3,33 +3,135 @@ package repo
 import (
 	"context"
 	"database/sql"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	zerolog "github.com/rs/zerolog/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timeouts holding the longest durations of the repository operations; they shorten the context of the caller
+type Timeouts struct {
+	// Read is used by the operations that read one row or one user
+	Read time.Duration
+	// Write is used by inserts, updates, deletes and transactions
+	Write time.Duration
+	// List is used by the operations that read many rows
+	List time.Duration
+}
+
+// DefaultTimeouts are the Timeouts of a new DBHolder
+var DefaultTimeouts = Timeouts{
+	Read:  6 * time.Second,
+	Write: 10 * time.Second,
+	List:  25 * time.Second,
+}
+
+// Names of the database/sql drivers of the repository
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
 )
 
 type DBHolder struct {
-	DB         *sql.DB
-	Statements map[string]*sql.Stmt
+	DB *sql.DB
+	// Driver is DriverSQLite or DriverPostgres; the queries are written with ? and rebound for postgres
+	Driver       string
+	Statements   *StatementRegistry
+	Timeouts     Timeouts
+	sessionStore *SessionStore
+	// tx is set on the DBHolder that WithTx passes to its function
+	tx *sql.Tx
 }
 
 var dbh *DBHolder
 
-func NewDriver(dsn string) (*DBHolder, error) {
-	db, err := sql.Open("sqlite3", dsn)
+// NewDriver use for opening the database of dsn with opts; a postgres:// or postgresql:// dsn opens postgres
+// and everything else is the path of a sqlite database that gets the sqlite options in its dsn
+func NewDriver(dsn string, opts DriverOptions) (*DBHolder, error) {
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	driver := DriverFor(dsn)
+	if driver == DriverSQLite {
+		dsn = opts.SQLiteDSN(dsn)
+	}
+
+	db, err := sql.Open(driver, dsn)
 	if err != nil {
 		zerolog.Fatal().Msg(err.Error())
 		return nil, err
 	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
 
 	dbh = &DBHolder{
-		DB: db,
+		DB:         db,
+		Driver:     driver,
+		Statements: NewStatementRegistry(),
+		Timeouts:   DefaultTimeouts,
 	}
 
 	return dbh, nil
 }
 
-func (d *DBHolder) PingingDB() error {
-	err := d.DB.Ping()
+// DriverFor use for getting the driver of a dsn by its scheme
+func DriverFor(dsn string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return DriverPostgres
+	}
+
+	return DriverSQLite
+}
+
+// querier is the part of *sql.DB and *sql.Tx that the operations run their queries on
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// db use for getting the querier of d; in the function of WithTx it is the transaction, so the operations
+// do not escape it
+func (d *DBHolder) db() querier {
+	if d.tx != nil {
+		return d.tx
+	}
+
+	return d.DB
+}
+
+// rebind use for replacing the ? placeholders of a query by $1, $2, ... for postgres
+func (d *DBHolder) rebind(query string) string {
+	if d.Driver != DriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// PingingDB use for checking the database is reachable; in a transaction the connection of the transaction
+// is already open, and pinging would take another connection of the pool while the transaction holds its one
+func (d *DBHolder) PingingDB(ctx context.Context) error {
+	if d.tx != nil {
+		return nil
+	}
+
+	err := d.DB.PingContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -38,7 +140,16 @@ func (d *DBHolder) PingingDB() error {
 }
 
 func (d *DBHolder) Dispose() error {
-	err := d.DB.Close()
+	if d.sessionStore != nil {
+		d.sessionStore.StopCleanup()
+	}
+
+	err := d.Statements.Close()
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+	}
+
+	err = d.DB.Close()
 	if err != nil {
 		return err
 	}
@@ -46,14 +157,15 @@ func (d *DBHolder) Dispose() error {
 	return nil
 }
 
+// CreateStatement use for preparing a query and storing it in Statements by its name
 func (d *DBHolder) CreateStatement(ctx context.Context, name string, query string) error {
-	stmt, err := d.DB.PrepareContext(ctx, query)
+	stmt, err := d.DB.PrepareContext(ctx, d.rebind(query))
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	d.Statements[name] = stmt
+	d.Statements.Add(name, stmt)
 
 	return nil
 }
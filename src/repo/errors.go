@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"regexp"
+	"strings"
+)
+
+// Kinds of the domain errors that the backends return; errors.Is(err, ErrNotFound) matches every *Error of the kind
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+	// ErrForeignKey is also returned alone by the backends that check the owner of a row themselves
+	ErrForeignKey = errors.New("foreign key violation")
+)
+
+// Error is a domain error of a backend; Message and Field can be shown to clients and Err is the cause
+// that is only logged
+type Error struct {
+	Kind    error
+	Field   string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is use for matching the error by its kind
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// NotFound use for making the error of a resource that does not exist; it is also sql.ErrNoRows
+func NotFound(resource string) error {
+	return &Error{Kind: ErrNotFound, Message: resource + " is not found", Err: sql.ErrNoRows}
+}
+
+// Invalid use for making the error of a field that has an invalid value
+func Invalid(field, message string) error {
+	return &Error{Kind: ErrValidation, Field: field, Message: message}
+}
+
+// UniqueError use for making the error of a unique column like cars.vin; its field is the name of the column
+func UniqueError(column string) error {
+	field := column[strings.LastIndex(column, ".")+1:]
+	return &Error{Kind: ErrConflict, Field: field, Message: field + " is already taken"}
+}
+
+// ForeignKeyError use for making the error of a field that refers to a row that does not exist
+func ForeignKeyError(field, message string) error {
+	return &Error{Kind: ErrForeignKey, Field: field, Message: message}
+}
+
+// Constraints of sqlite that translate recognizes
+const (
+	constraintNone = iota
+	constraintUnique
+	constraintForeignKey
+)
+
+var (
+	sqliteUnique  = regexp.MustCompile(`UNIQUE constraint failed: ([\w.]+)`)
+	postgresField = regexp.MustCompile(`^Key \((\w+)\)=`)
+)
+
+// translate use for replacing the constraint errors of sqlite and postgres by the domain errors;
+// the other errors are returned as they are
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		field := ""
+		if match := postgresField.FindStringSubmatch(pqErr.Detail); match != nil {
+			field = match[1]
+		}
+
+		switch pqErr.Code {
+		case "23505":
+			if field == "" {
+				field = pqErr.Column
+			}
+			return &Error{Kind: ErrConflict, Field: field, Message: field + " is already taken", Err: err}
+		case "23503":
+			return &Error{Kind: ErrForeignKey, Field: field, Message: "the row refers to a row that does not exist", Err: err}
+		}
+
+		return err
+	}
+
+	switch sqliteConstraint(err) {
+	case constraintUnique:
+		unique := UniqueError("")
+		if match := sqliteUnique.FindStringSubmatch(err.Error()); match != nil {
+			unique = UniqueError(match[1])
+		}
+		unique.(*Error).Err = err
+		return unique
+	case constraintForeignKey:
+		return &Error{Kind: ErrForeignKey, Message: "the row refers to a row that does not exist", Err: err}
+	}
+
+	return err
+}
+
+// NoUser use for making the error of an operation on a user that does not exist
+func NoUser(userID int) error {
+	return &Error{Kind: ErrNotFound, Field: "user_id", Message: fmt.Sprintf("there is no user with this id=%d", userID), Err: sql.ErrNoRows}
+}
@@ -0,0 +1,153 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	zerolog "github.com/rs/zerolog/log"
+	"time"
+)
+
+// AuthFailureOps holding the operations of the failed attempts and the lockouts
+type AuthFailureOps interface {
+	GetAuthFailure(ctx context.Context, kind, subject string) (*models.AuthFailure, error)
+	RecordAuthFailure(ctx context.Context, kind, subject string, lockAfter int, lockFor, window time.Duration) (*models.AuthFailure, error)
+	ResetAuthFailures(ctx context.Context, kind, subject string) error
+	GetAuthFailures(ctx context.Context) ([]*models.AuthFailure, error)
+}
+
+// GetAuthFailure use for getting the failed attempts of a user or an ip; nil means no failure is recorded
+func (d *DBHolder) GetAuthFailure(ctx context.Context, kind, subject string) (*models.AuthFailure, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Read)
+	defer cancel()
+
+	query := `SELECT kind,subject,failures,last_failure,locked_until FROM auth_failures WHERE kind=? AND subject=?`
+	f, err := scanAuthFailure(d.db().QueryRowContext(ctx, d.rebind(query), kind, subject))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// RecordAuthFailure use for counting a failed attempt; the count restarts when the last failure is older
+// than window and the subject is not locked, and the subject is locked for lockFor when it reaches lockAfter failures
+func (d *DBHolder) RecordAuthFailure(ctx context.Context, kind, subject string, lockAfter int, lockFor, window time.Duration) (*models.AuthFailure, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	if d.tx == nil {
+		var f *models.AuthFailure
+		err := d.WithTx(ctx, func(tx Repo) error {
+			var err error
+			f, err = tx.RecordAuthFailure(ctx, kind, subject, lockAfter, lockFor, window)
+			return err
+		})
+		return f, err
+	}
+
+	now := time.Now().UTC()
+	query := `SELECT kind,subject,failures,last_failure,locked_until FROM auth_failures WHERE kind=? AND subject=?`
+	f, err := scanAuthFailure(d.tx.QueryRowContext(ctx, d.rebind(query), kind, subject))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && now.Sub(f.LastFailure) > window && !f.LockedAt(now)) {
+		f = &models.AuthFailure{Kind: kind, Subject: subject}
+	} else if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	f.Failures++
+	f.LastFailure = now
+	if f.Failures >= lockAfter {
+		lockedUntil := now.Add(lockFor)
+		f.LockedUntil = &lockedUntil
+	}
+
+	query = `INSERT INTO auth_failures (kind,subject,failures,last_failure,locked_until) VALUES (?,?,?,?,?)
+ON CONFLICT ( kind , subject ) DO UPDATE SET failures=excluded.failures , last_failure=excluded.last_failure , locked_until=excluded.locked_until`
+	_, err = d.tx.ExecContext(ctx, d.rebind(query), f.Kind, f.Subject, f.Failures, f.LastFailure, f.LockedUntil)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ResetAuthFailures use for removing the failed attempts and the lockout of a user or an ip
+func (d *DBHolder) ResetAuthFailures(ctx context.Context, kind, subject string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	query := `DELETE FROM auth_failures WHERE kind=? AND subject=?`
+	_, err := d.db().ExecContext(ctx, d.rebind(query), kind, subject)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// GetAuthFailures use for listing every user and ip that has failed attempts
+func (d *DBHolder) GetAuthFailures(ctx context.Context) ([]*models.AuthFailure, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.List)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	query := `SELECT kind,subject,failures,last_failure,locked_until FROM auth_failures ORDER BY last_failure DESC`
+	results, err := d.db().QueryContext(ctx, d.rebind(query))
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+	defer func(results *sql.Rows) {
+		err := results.Close()
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+			return
+		}
+	}(results)
+
+	var failures []*models.AuthFailure = []*models.AuthFailure{}
+	for results.Next() {
+		f, err := scanAuthFailure(results)
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+			return nil, err
+		}
+
+		failures = append(failures, f)
+	}
+
+	return failures, results.Err()
+}
+
+func scanAuthFailure(row rowScanner) (*models.AuthFailure, error) {
+	f := &models.AuthFailure{}
+	var lockedUntil sql.NullTime
+	err := row.Scan(&f.Kind,
+		&f.Subject,
+		&f.Failures,
+		&f.LastFailure,
+		&lockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockedUntil.Valid {
+		f.LockedUntil = &lockedUntil.Time
+	}
+
+	return f, nil
+}
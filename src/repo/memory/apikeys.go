@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"sort"
+	"time"
+)
+
+type apiKeyRow struct {
+	key  models.ApiKey
+	hash string
+}
+
+// AddApiKey use for storing a new api key with the sha256 hash of its key
+func (m *Store) AddApiKey(ctx context.Context, key *models.ApiKey, keyHash string) error {
+	return m.write(ctx, func(t *tables) error {
+		if !t.userExists(key.UserID) {
+			return repo.ErrForeignKey
+		}
+		for _, row := range t.apiKeys {
+			if row.hash == keyHash {
+				return repo.UniqueError("api_keys.key_hash")
+			}
+		}
+
+		key.ID = t.nextID("api_keys")
+		row := apiKeyRow{key: *key, hash: keyHash}
+		row.key.Scopes = append([]string{}, key.Scopes...)
+		t.apiKeys[key.ID] = row
+
+		return nil
+	})
+}
+
+// GetApiKeysByUser use for listing every api key of a user
+func (m *Store) GetApiKeysByUser(ctx context.Context, userID int) ([]*models.ApiKey, error) {
+	var keys []*models.ApiKey = []*models.ApiKey{}
+	err := m.read(ctx, func(t *tables) error {
+		for _, row := range t.apiKeys {
+			if row.key.UserID == userID {
+				keys = append(keys, row.copy())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+// GetApiKeyByHash use for finding an api key by the sha256 hash of its key
+func (m *Store) GetApiKeyByHash(ctx context.Context, keyHash string) (*models.ApiKey, error) {
+	var key *models.ApiKey
+	err := m.read(ctx, func(t *tables) error {
+		for _, row := range t.apiKeys {
+			if row.hash == keyHash {
+				key = row.copy()
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// TouchApiKey use for recording the last time an api key used
+func (m *Store) TouchApiKey(ctx context.Context, keyID int) error {
+	return m.write(ctx, func(t *tables) error {
+		row, ok := t.apiKeys[keyID]
+		if ok {
+			row.key.LastUsedAt = timePtr(time.Now().UTC())
+			t.apiKeys[keyID] = row
+		}
+		return nil
+	})
+}
+
+// RevokeApiKey use for revoking an api key of a user
+func (m *Store) RevokeApiKey(ctx context.Context, keyID, userID int) error {
+	return m.write(ctx, func(t *tables) error {
+		row, ok := t.apiKeys[keyID]
+		if !ok || row.key.UserID != userID || row.key.RevokedAt != nil {
+			return &repo.Error{Kind: repo.ErrNotFound, Field: "id", Message: fmt.Sprintf("there is no active api key with this id=%d", keyID)}
+		}
+
+		row.key.RevokedAt = timePtr(time.Now().UTC())
+		t.apiKeys[keyID] = row
+
+		return nil
+	})
+}
+
+func (r apiKeyRow) copy() *models.ApiKey {
+	key := r.key
+	key.Scopes = append([]string{}, r.key.Scopes...)
+	return &key
+}
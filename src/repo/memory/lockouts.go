@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"sort"
+	"time"
+)
+
+func failureKey(kind, subject string) string {
+	return kind + "\x00" + subject
+}
+
+// GetAuthFailure use for getting the failed attempts of a user or an ip; nil means no failure is recorded
+func (m *Store) GetAuthFailure(ctx context.Context, kind, subject string) (*models.AuthFailure, error) {
+	var f *models.AuthFailure
+	err := m.read(ctx, func(t *tables) error {
+		row, ok := t.authFailures[failureKey(kind, subject)]
+		if ok {
+			f = &row
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// RecordAuthFailure use for counting a failed attempt; the count restarts when the last failure is older
+// than window and the subject is not locked, and the subject is locked for lockFor when it reaches lockAfter failures
+func (m *Store) RecordAuthFailure(ctx context.Context, kind, subject string, lockAfter int, lockFor, window time.Duration) (*models.AuthFailure, error) {
+	var f models.AuthFailure
+	err := m.write(ctx, func(t *tables) error {
+		now := time.Now().UTC()
+		row, ok := t.authFailures[failureKey(kind, subject)]
+		if !ok || (now.Sub(row.LastFailure) > window && !row.LockedAt(now)) {
+			row = models.AuthFailure{Kind: kind, Subject: subject}
+		}
+
+		row.Failures++
+		row.LastFailure = now
+		if row.Failures >= lockAfter {
+			row.LockedUntil = timePtr(now.Add(lockFor))
+		}
+		t.authFailures[failureKey(kind, subject)] = row
+
+		f = row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// ResetAuthFailures use for removing the failed attempts and the lockout of a user or an ip
+func (m *Store) ResetAuthFailures(ctx context.Context, kind, subject string) error {
+	return m.write(ctx, func(t *tables) error {
+		delete(t.authFailures, failureKey(kind, subject))
+		return nil
+	})
+}
+
+// GetAuthFailures use for listing every user and ip that has failed attempts
+func (m *Store) GetAuthFailures(ctx context.Context) ([]*models.AuthFailure, error) {
+	var failures []*models.AuthFailure = []*models.AuthFailure{}
+	err := m.read(ctx, func(t *tables) error {
+		for _, row := range t.authFailures {
+			row := row
+			failures = append(failures, &row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].LastFailure.After(failures[j].LastFailure)
+	})
+
+	return failures, nil
+}
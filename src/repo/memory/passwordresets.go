@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"time"
+)
+
+type passwordResetRow struct {
+	userID    int
+	hash      string
+	createdAt time.Time
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+// AddPasswordReset use for storing a reset token hash of a user; older unused tokens of the user become invalid
+func (m *Store) AddPasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	return m.write(ctx, func(t *tables) error {
+		if !t.userExists(userID) {
+			return repo.ErrForeignKey
+		}
+		for _, row := range t.passwordResets {
+			if row.hash == tokenHash {
+				return repo.UniqueError("password_resets.token_hash")
+			}
+		}
+
+		now := time.Now().UTC()
+		for id, row := range t.passwordResets {
+			if row.userID == userID && row.usedAt == nil {
+				row.usedAt = timePtr(now)
+				t.passwordResets[id] = row
+			}
+		}
+
+		t.passwordResets[t.nextID("password_resets")] = passwordResetRow{
+			userID:    userID,
+			hash:      tokenHash,
+			createdAt: now,
+			expiresAt: expiresAt.UTC(),
+		}
+
+		return nil
+	})
+}
+
+// ConsumePasswordReset use for marking a reset token as used and returning its user; a token can be consumed once
+func (m *Store) ConsumePasswordReset(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	err := m.write(ctx, func(t *tables) error {
+		for id, row := range t.passwordResets {
+			if row.hash != tokenHash || row.usedAt != nil {
+				continue
+			}
+			if time.Now().After(row.expiresAt) {
+				return repo.ErrInvalidResetToken
+			}
+
+			row.usedAt = timePtr(time.Now().UTC())
+			t.passwordResets[id] = row
+			userID = row.userID
+			return nil
+		}
+		return repo.ErrInvalidResetToken
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
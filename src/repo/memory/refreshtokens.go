@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"time"
+)
+
+type refreshTokenRow struct {
+	token models.RefreshToken
+	hash  string
+}
+
+// AddRefreshToken use for storing a refresh token by the sha256 hash of its token
+func (m *Store) AddRefreshToken(ctx context.Context, token *models.RefreshToken, tokenHash string) error {
+	return m.write(ctx, func(t *tables) error {
+		if !t.userExists(token.UserID) {
+			return repo.ErrForeignKey
+		}
+		for _, row := range t.refreshTokens {
+			if row.hash == tokenHash {
+				return repo.UniqueError("refresh_tokens.token_hash")
+			}
+		}
+
+		token.ID = t.nextID("refresh_tokens")
+		t.refreshTokens[token.ID] = refreshTokenRow{token: *token, hash: tokenHash}
+
+		return nil
+	})
+}
+
+// GetRefreshTokenByHash use for finding a refresh token by the sha256 hash of its token
+func (m *Store) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token *models.RefreshToken
+	err := m.read(ctx, func(t *tables) error {
+		for _, row := range t.refreshTokens {
+			if row.hash == tokenHash {
+				found := row.token
+				token = &found
+				return nil
+			}
+		}
+		return sql.ErrNoRows
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// UseRefreshToken use for marking a refresh token as rotated; only one caller can use a token
+func (m *Store) UseRefreshToken(ctx context.Context, tokenID int) error {
+	return m.write(ctx, func(t *tables) error {
+		row, ok := t.refreshTokens[tokenID]
+		if !ok || row.token.UsedAt != nil {
+			return repo.ErrRefreshTokenUsed
+		}
+
+		row.token.UsedAt = timePtr(time.Now().UTC())
+		t.refreshTokens[tokenID] = row
+
+		return nil
+	})
+}
+
+// RevokeRefreshTokenFamily use for revoking a refresh token with every token that rotated from the same login
+func (m *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return m.revokeRefreshTokens(ctx, func(token models.RefreshToken) bool {
+		return token.FamilyID == familyID
+	})
+}
+
+// RevokeUserRefreshTokens use for revoking every refresh token of a user
+func (m *Store) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	return m.revokeRefreshTokens(ctx, func(token models.RefreshToken) bool {
+		return token.UserID == userID
+	})
+}
+
+func (m *Store) revokeRefreshTokens(ctx context.Context, match func(token models.RefreshToken) bool) error {
+	return m.write(ctx, func(t *tables) error {
+		now := time.Now().UTC()
+		for id, row := range t.refreshTokens {
+			if row.token.RevokedAt == nil && match(row.token) {
+				row.token.RevokedAt = timePtr(now)
+				t.refreshTokens[id] = row
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionStore is a scs.Store that keeps the sessions in memory beside the Store; unlike memstore it can list
+// its sessions, so the sessions of a user can be found and removed
+type SessionStore struct {
+	mu          sync.RWMutex
+	sessions    map[string]sessionRow
+	stopCleanup chan bool
+	doneCleanup chan bool
+}
+
+type sessionRow struct {
+	data   []byte
+	expiry time.Time
+}
+
+// NewSessionStore use for creating a SessionStore; if cleanupInterval is greater than zero
+// a background job removes the expired sessions every cleanupInterval until StopCleanup is called
+func (m *Store) NewSessionStore(cleanupInterval time.Duration) *SessionStore {
+	store := &SessionStore{
+		sessions: map[string]sessionRow{},
+	}
+
+	if cleanupInterval > 0 {
+		store.stopCleanup = make(chan bool)
+		store.doneCleanup = make(chan bool)
+		go store.startCleanup(cleanupInterval)
+	}
+
+	return store
+}
+
+// Find use for getting the data of a session token that is not expired
+func (ss *SessionStore) Find(token string) ([]byte, bool, error) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	row, ok := ss.sessions[token]
+	if !ok || !row.expiry.After(time.Now()) {
+		return nil, false, nil
+	}
+
+	return row.data, true, nil
+}
+
+// Commit use for adding a session token or replacing its data and expiry
+func (ss *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.sessions[token] = sessionRow{data: append([]byte{}, b...), expiry: expiry}
+	return nil
+}
+
+// Delete use for removing a session token
+func (ss *SessionStore) Delete(token string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	delete(ss.sessions, token)
+	return nil
+}
+
+// All use for getting the data of every session that is not expired by its token
+func (ss *SessionStore) All() (map[string][]byte, error) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	now := time.Now()
+	sessions := map[string][]byte{}
+	for token, row := range ss.sessions {
+		if row.expiry.After(now) {
+			sessions[token] = row.data
+		}
+	}
+
+	return sessions, nil
+}
+
+// deleteExpired use for removing every session that its expiry passed
+func (ss *SessionStore) deleteExpired() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	now := time.Now()
+	for token, row := range ss.sessions {
+		if !row.expiry.After(now) {
+			delete(ss.sessions, token)
+		}
+	}
+}
+
+func (ss *SessionStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer close(ss.doneCleanup)
+
+	for {
+		select {
+		case <-ticker.C:
+			ss.deleteExpired()
+		case <-ss.stopCleanup:
+			return
+		}
+	}
+}
+
+// StopCleanup use for terminating the background cleanup job and waiting for it
+func (ss *SessionStore) StopCleanup() {
+	if ss.stopCleanup == nil {
+		return
+	}
+
+	close(ss.stopCleanup)
+	<-ss.doneCleanup
+	ss.stopCleanup = nil
+}
@@ -0,0 +1,200 @@
+package memory
+
+import (
+	"context"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"sync"
+	"time"
+)
+
+var _ repo.ApiOpsInterface = (*Store)(nil)
+
+// Store is an ApiOpsInterface that keeps everything in memory; it is safe for concurrent use and it has the
+// constraints of the sqlite schema: unique vin, number plate and hashes, the owner of a row must exist
+// and deleting a user deletes its cars, keys, tokens and two factor data
+type Store struct {
+	mu *sync.RWMutex
+	t  *tables
+	// inTx is set on the Store that WithTx passes to its function; it runs under the lock of WithTx
+	inTx bool
+}
+
+type tables struct {
+	seq            map[string]int
+	users          map[int]models.Users
+	cars           map[int]models.Cars
+	apiKeys        map[int]apiKeyRow
+	refreshTokens  map[int]refreshTokenRow
+	passwordResets map[int]passwordResetRow
+	totps          map[int]totpRow
+	recoveryCodes  map[int]recoveryCodeRow
+	authFailures   map[string]models.AuthFailure
+}
+
+// NewStore use for creating an empty Store
+func NewStore() *Store {
+	return &Store{
+		mu: &sync.RWMutex{},
+		t: &tables{
+			seq:            map[string]int{},
+			users:          map[int]models.Users{},
+			cars:           map[int]models.Cars{},
+			apiKeys:        map[int]apiKeyRow{},
+			refreshTokens:  map[int]refreshTokenRow{},
+			passwordResets: map[int]passwordResetRow{},
+			totps:          map[int]totpRow{},
+			recoveryCodes:  map[int]recoveryCodeRow{},
+			authFailures:   map[string]models.AuthFailure{},
+		},
+	}
+}
+
+// WithTx use for running fn in a transaction; fn changes a copy of the tables that replaces them when fn
+// returns nil, so an error or a panic leaves the Store as it was. Transactions run one at a time
+func (m *Store) WithTx(ctx context.Context, fn func(tx repo.Repo) error) error {
+	if m.inTx {
+		return fn(m)
+	}
+
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &Store{mu: m.mu, t: m.t.clone(), inTx: true}
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	m.t = tx.t
+	return nil
+}
+
+// read use for running fn while no write runs
+func (m *Store) read(ctx context.Context, fn func(t *tables) error) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	if m.inTx {
+		return fn(m.t)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return fn(m.t)
+}
+
+// write use for running fn while nothing else runs; fn checks every constraint before it changes a table,
+// so a returned error leaves the tables as they were
+func (m *Store) write(ctx context.Context, fn func(t *tables) error) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	if m.inTx {
+		return fn(m.t)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return fn(m.t)
+}
+
+// nextID use for getting the next id of a table like an autoincrement column
+func (t *tables) nextID(table string) int {
+	t.seq[table]++
+	return t.seq[table]
+}
+
+func (t *tables) userExists(userID int) bool {
+	_, ok := t.users[userID]
+	return ok
+}
+
+// deleteUser use for deleting a user with every row that refers to it like ON DELETE CASCADE
+func (t *tables) deleteUser(userID int) {
+	delete(t.users, userID)
+	delete(t.totps, userID)
+	for id, car := range t.cars {
+		if car.OwnerID == userID {
+			delete(t.cars, id)
+		}
+	}
+	for id, key := range t.apiKeys {
+		if key.key.UserID == userID {
+			delete(t.apiKeys, id)
+		}
+	}
+	for id, token := range t.refreshTokens {
+		if token.token.UserID == userID {
+			delete(t.refreshTokens, id)
+		}
+	}
+	for id, reset := range t.passwordResets {
+		if reset.userID == userID {
+			delete(t.passwordResets, id)
+		}
+	}
+	for id, code := range t.recoveryCodes {
+		if code.userID == userID {
+			delete(t.recoveryCodes, id)
+		}
+	}
+}
+
+// clone use for copying the tables for a transaction; rows are values and their pointers are replaced
+// and never changed, so copying the maps is enough
+func (t *tables) clone() *tables {
+	c := &tables{
+		seq:            make(map[string]int, len(t.seq)),
+		users:          make(map[int]models.Users, len(t.users)),
+		cars:           make(map[int]models.Cars, len(t.cars)),
+		apiKeys:        make(map[int]apiKeyRow, len(t.apiKeys)),
+		refreshTokens:  make(map[int]refreshTokenRow, len(t.refreshTokens)),
+		passwordResets: make(map[int]passwordResetRow, len(t.passwordResets)),
+		totps:          make(map[int]totpRow, len(t.totps)),
+		recoveryCodes:  make(map[int]recoveryCodeRow, len(t.recoveryCodes)),
+		authFailures:   make(map[string]models.AuthFailure, len(t.authFailures)),
+	}
+	for k, v := range t.seq {
+		c.seq[k] = v
+	}
+	for k, v := range t.users {
+		c.users[k] = v
+	}
+	for k, v := range t.cars {
+		c.cars[k] = v
+	}
+	for k, v := range t.apiKeys {
+		c.apiKeys[k] = v
+	}
+	for k, v := range t.refreshTokens {
+		c.refreshTokens[k] = v
+	}
+	for k, v := range t.passwordResets {
+		c.passwordResets[k] = v
+	}
+	for k, v := range t.totps {
+		c.totps[k] = v
+	}
+	for k, v := range t.recoveryCodes {
+		c.recoveryCodes[k] = v
+	}
+	for k, v := range t.authFailures {
+		c.authFailures[k] = v
+	}
+
+	return c
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
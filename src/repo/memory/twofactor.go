@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"time"
+)
+
+type totpRow struct {
+	secret      string
+	createdAt   time.Time
+	confirmedAt *time.Time
+	lastStep    int64
+}
+
+type recoveryCodeRow struct {
+	userID int
+	hash   string
+	usedAt *time.Time
+}
+
+// SetTOTPSecret use for storing a new unconfirmed totp secret of a user
+func (m *Store) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	return m.write(ctx, func(t *tables) error {
+		if !t.userExists(userID) {
+			return repo.ErrForeignKey
+		}
+
+		row, ok := t.totps[userID]
+		if ok && row.confirmedAt != nil {
+			return repo.ErrTOTPEnabled
+		}
+
+		t.totps[userID] = totpRow{secret: secret, createdAt: time.Now().UTC()}
+		return nil
+	})
+}
+
+// GetTOTP use for getting the totp enrollment of a user; sql.ErrNoRows means the user never enrolled
+func (m *Store) GetTOTP(ctx context.Context, userID int) (*models.TOTP, error) {
+	var totp *models.TOTP
+	err := m.read(ctx, func(t *tables) error {
+		row, ok := t.totps[userID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		totp = &models.TOTP{
+			UserID:    userID,
+			Secret:    row.secret,
+			Confirmed: row.confirmedAt != nil,
+			LastStep:  row.lastStep,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return totp, nil
+}
+
+// ConfirmTOTP use for enabling the totp of a user and replacing its recovery codes
+func (m *Store) ConfirmTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	return m.write(ctx, func(t *tables) error {
+		row, ok := t.totps[userID]
+		if !ok || row.confirmedAt != nil {
+			return repo.ErrTOTPEnabled
+		}
+
+		err := t.replaceRecoveryCodes(userID, codeHashes)
+		if err != nil {
+			return err
+		}
+
+		row.confirmedAt = timePtr(time.Now().UTC())
+		row.lastStep = step
+		t.totps[userID] = row
+
+		return nil
+	})
+}
+
+// ReplaceRecoveryCodes use for generating a new set of recovery codes; the old ones become invalid
+func (m *Store) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	return m.write(ctx, func(t *tables) error {
+		return t.replaceRecoveryCodes(userID, codeHashes)
+	})
+}
+
+// replaceRecoveryCodes use for checking the new codes before the old codes of the user are deleted
+func (t *tables) replaceRecoveryCodes(userID int, codeHashes []string) error {
+	if len(codeHashes) > 0 && !t.userExists(userID) {
+		return repo.ErrForeignKey
+	}
+
+	seen := map[string]bool{}
+	for _, row := range t.recoveryCodes {
+		if row.userID != userID {
+			seen[row.hash] = true
+		}
+	}
+	for _, h := range codeHashes {
+		if seen[h] {
+			return repo.UniqueError("recovery_codes.code_hash")
+		}
+		seen[h] = true
+	}
+
+	for id, row := range t.recoveryCodes {
+		if row.userID == userID {
+			delete(t.recoveryCodes, id)
+		}
+	}
+	for _, h := range codeHashes {
+		t.recoveryCodes[t.nextID("recovery_codes")] = recoveryCodeRow{userID: userID, hash: h}
+	}
+
+	return nil
+}
+
+// UseTOTPStep use for recording the time step of an accepted code; older or equal steps are rejected
+func (m *Store) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	return m.write(ctx, func(t *tables) error {
+		row, ok := t.totps[userID]
+		if !ok || row.lastStep >= step {
+			return repo.ErrTOTPReplayed
+		}
+
+		row.lastStep = step
+		t.totps[userID] = row
+
+		return nil
+	})
+}
+
+// UseRecoveryCode use for consuming a recovery code of a user
+func (m *Store) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	return m.write(ctx, func(t *tables) error {
+		for id, row := range t.recoveryCodes {
+			if row.userID == userID && row.hash == codeHash && row.usedAt == nil {
+				row.usedAt = timePtr(time.Now().UTC())
+				t.recoveryCodes[id] = row
+				return nil
+			}
+		}
+		return repo.ErrRecoveryCodeInvalid
+	})
+}
+
+// DisableTOTP use for removing the totp and recovery codes of a user
+func (m *Store) DisableTOTP(ctx context.Context, userID int) error {
+	return m.write(ctx, func(t *tables) error {
+		for id, row := range t.recoveryCodes {
+			if row.userID == userID {
+				delete(t.recoveryCodes, id)
+			}
+		}
+		delete(t.totps, userID)
+
+		return nil
+	})
+}
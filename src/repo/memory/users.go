@@ -0,0 +1,355 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo"
+	"sort"
+	"time"
+)
+
+// AddUser use for adding a user; its id is set like the autoincrement id of sqlite
+func (m *Store) AddUser(ctx context.Context, user *models.Users) error {
+	_, err := time.Parse(repo.BirthDayLayout, user.BirthDay)
+	if err != nil {
+		return repo.Invalid("birth_day", "birth_day is not a date like "+repo.BirthDayLayout)
+	}
+
+	if user.Role == "" {
+		user.Role = models.RoleOwner
+	}
+
+	return m.write(ctx, func(t *tables) error {
+		user.ID = t.nextID("users")
+
+		row := *user
+		row.UsersCars = nil
+		t.users[user.ID] = row
+
+		return nil
+	})
+}
+
+// DeleteUser use for deleting a user with its cars, keys, tokens and two factor data
+func (m *Store) DeleteUser(ctx context.Context, userID int) error {
+	return m.write(ctx, func(t *tables) error {
+		if !t.userExists(userID) {
+			return repo.NotFound("user")
+		}
+
+		t.deleteUser(userID)
+		return nil
+	})
+}
+
+// DeleteCar use for deleting a car by its id
+func (m *Store) DeleteCar(ctx context.Context, carID int) error {
+	return m.write(ctx, func(t *tables) error {
+		_, ok := t.cars[carID]
+		if !ok {
+			return repo.NotFound("car")
+		}
+
+		delete(t.cars, carID)
+		return nil
+	})
+}
+
+// AddCar use for adding a car of an existing user; its vin and number plate must be unique
+func (m *Store) AddCar(ctx context.Context, car *models.Cars) error {
+	return m.write(ctx, func(t *tables) error {
+		if !t.userExists(car.OwnerID) {
+			return repo.ForeignKeyError("owner_id", fmt.Sprintf("there is no user with this id=%d", car.OwnerID))
+		}
+
+		err := t.checkCar(car)
+		if err != nil {
+			return err
+		}
+
+		car.ID = t.nextID("cars")
+		t.cars[car.ID] = *car
+
+		return nil
+	})
+}
+
+// GetUserByID use for getting a user with its cars; repo.ErrNotFound means the user does not exist
+func (m *Store) GetUserByID(ctx context.Context, userID int) (*models.Users, error) {
+	var user *models.Users
+	err := m.read(ctx, func(t *tables) error {
+		row, ok := t.users[userID]
+		if !ok {
+			return repo.NotFound("user")
+		}
+
+		user = t.userWithCars(row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetAllUsers use for getting a page of users in the order of their ids with their cars
+func (m *Store) GetAllUsers(ctx context.Context, limit, offset int) ([]*models.Users, error) {
+	var users []*models.Users
+	err := m.read(ctx, func(t *tables) error {
+		ids := make([]int, 0, len(t.users))
+		for id := range t.users {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+
+		// like sqlite a negative offset is no offset and a negative limit is no limit
+		if offset < 0 {
+			offset = 0
+		}
+		if offset > len(ids) {
+			offset = len(ids)
+		}
+		ids = ids[offset:]
+		if limit >= 0 && limit < len(ids) {
+			ids = ids[:limit]
+		}
+
+		for _, id := range ids {
+			users = append(users, t.userWithCars(t.users[id]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// UpdateUser use for update the profile of a user; the password is changed by UpdateUserPassword
+func (m *Store) UpdateUser(ctx context.Context, user *models.Users) error {
+	return m.write(ctx, func(t *tables) error {
+		row, ok := t.users[user.ID]
+		if !ok {
+			return repo.NotFound("user")
+		}
+
+		row.CompleteName = user.CompleteName
+		row.Sex = user.Sex
+		row.BirthDay = user.BirthDay
+		t.users[user.ID] = row
+
+		return nil
+	})
+}
+
+// UpdateCar use for update a car by its id; its new vin and number plate must be unique
+func (m *Store) UpdateCar(ctx context.Context, car *models.Cars) error {
+	return m.write(ctx, func(t *tables) error {
+		row, ok := t.cars[car.ID]
+		if !ok {
+			return repo.NotFound("car")
+		}
+
+		err := t.checkCar(car)
+		if err != nil {
+			return err
+		}
+
+		row.NumberPlate = car.NumberPlate
+		row.Color = car.Color
+		row.VIN = car.VIN
+		t.cars[car.ID] = row
+
+		return nil
+	})
+}
+
+// PatchUser use for changing only the fields of a user that patch has; it returns the updated user with its cars
+func (m *Store) PatchUser(ctx context.Context, userID int, patch *models.UserPatch) (*models.Users, error) {
+	var user *models.Users
+	err := m.write(ctx, func(t *tables) error {
+		row, ok := t.users[userID]
+		if !ok {
+			return repo.NotFound("user")
+		}
+
+		if patch.CompleteName != nil {
+			row.CompleteName = *patch.CompleteName
+		}
+		if patch.Sex != nil {
+			row.Sex = *patch.Sex
+		}
+		if patch.BirthDay != nil {
+			row.BirthDay = *patch.BirthDay
+		}
+		t.users[userID] = row
+
+		user = t.userWithCars(row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// PatchCar use for changing only the fields of a car that patch has; its new vin and number plate must be unique
+func (m *Store) PatchCar(ctx context.Context, carID int, patch *models.CarPatch) (*models.Cars, error) {
+	var car *models.Cars
+	err := m.write(ctx, func(t *tables) error {
+		row, ok := t.cars[carID]
+		if !ok {
+			return repo.NotFound("car")
+		}
+
+		if patch.NumberPlate != nil {
+			row.NumberPlate = *patch.NumberPlate
+		}
+		if patch.Color != nil {
+			row.Color = *patch.Color
+		}
+		if patch.VIN != nil {
+			row.VIN = *patch.VIN
+		}
+
+		err := t.checkCar(&row)
+		if err != nil {
+			return err
+		}
+		t.cars[carID] = row
+
+		updated := row
+		car = &updated
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return car, nil
+}
+
+// GetUserPassword use for getting the stored password hash of a user for authentication
+func (m *Store) GetUserPassword(ctx context.Context, userID int) (string, error) {
+	var password string
+	err := m.read(ctx, func(t *tables) error {
+		row, ok := t.users[userID]
+		if !ok {
+			return repo.NoUser(userID)
+		}
+
+		password = row.Password
+		return nil
+	})
+
+	return password, err
+}
+
+// UpdateUserPassword use for replacing the password hash of a user
+func (m *Store) UpdateUserPassword(ctx context.Context, userID int, password string) error {
+	return m.write(ctx, func(t *tables) error {
+		row, ok := t.users[userID]
+		if !ok {
+			return repo.NoUser(userID)
+		}
+
+		row.Password = password
+		t.users[userID] = row
+
+		return nil
+	})
+}
+
+// GetUserRole use for getting the role of a user for authorization
+func (m *Store) GetUserRole(ctx context.Context, userID int) (models.Role, error) {
+	var role models.Role
+	err := m.read(ctx, func(t *tables) error {
+		row, ok := t.users[userID]
+		if !ok {
+			return repo.NoUser(userID)
+		}
+
+		role = row.Role
+		return nil
+	})
+
+	return role, err
+}
+
+// SetUserRole use for changing the role of a user
+func (m *Store) SetUserRole(ctx context.Context, userID int, role models.Role) error {
+	if !role.Valid() {
+		return repo.Invalid("role", fmt.Sprintf("%s is not a valid role", role))
+	}
+
+	return m.write(ctx, func(t *tables) error {
+		row, ok := t.users[userID]
+		if !ok {
+			return repo.NoUser(userID)
+		}
+
+		row.Role = role
+		t.users[userID] = row
+
+		return nil
+	})
+}
+
+// GetCarByID use for getting a car by its id
+func (m *Store) GetCarByID(ctx context.Context, carID int) (*models.Cars, error) {
+	var car *models.Cars
+	err := m.read(ctx, func(t *tables) error {
+		row, ok := t.cars[carID]
+		if !ok {
+			return repo.NotFound("car")
+		}
+
+		car = &row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return car, nil
+}
+
+// checkCar use for checking no other car has the vin or the number plate of car
+func (t *tables) checkCar(car *models.Cars) error {
+	for id, other := range t.cars {
+		if id == car.ID {
+			continue
+		}
+		if other.VIN == car.VIN {
+			return repo.UniqueError("cars.vin")
+		}
+		if other.NumberPlate == car.NumberPlate {
+			return repo.UniqueError("cars.number_plate")
+		}
+	}
+
+	return nil
+}
+
+// userWithCars use for copying a user with its cars in the order of their ids; the password is not copied
+// like the sqlite backend does not read it with the profile
+func (t *tables) userWithCars(row models.Users) *models.Users {
+	user := row
+	user.Password = ""
+	user.UsersCars = []*models.Cars{}
+	for _, car := range t.cars {
+		if car.OwnerID == user.ID {
+			car := car
+			user.UsersCars = append(user.UsersCars, &car)
+		}
+	}
+	sort.Slice(user.UsersCars, func(i, j int) bool {
+		return user.UsersCars[i].ID < user.UsersCars[j].ID
+	})
+
+	return &user
+}
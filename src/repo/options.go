@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Journal modes of sqlite; WAL lets the readers run while a write runs
+const (
+	JournalDelete   = "DELETE"
+	JournalTruncate = "TRUNCATE"
+	JournalPersist  = "PERSIST"
+	JournalMemory   = "MEMORY"
+	JournalWAL      = "WAL"
+	JournalOff      = "OFF"
+)
+
+// Synchronous levels of sqlite in the order of the values that PRAGMA synchronous returns
+var synchronousLevels = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+
+// DriverOptions holding the settings of the database connections; the sqlite fields are added to the dsn
+// of a sqlite database and CheckOptions checks them, the pool fields are used by both drivers
+type DriverOptions struct {
+	// JournalMode is one of the Journal modes
+	JournalMode string
+	// Synchronous is OFF, NORMAL, FULL or EXTRA; NORMAL does not lose a committed write in WAL mode
+	// unless the machine loses its power
+	Synchronous string
+	// BusyTimeout is how long a connection waits for the lock of another connection before it returns
+	// database is locked
+	BusyTimeout time.Duration
+	// ForeignKeys turns on the foreign keys of every connection; ON DELETE CASCADE needs them
+	ForeignKeys bool
+	// CacheSize is the page cache of every connection; pages when it is positive and KiB when it is negative
+	CacheSize int
+	// MaxOpenConns is the limit of the open connections; zero means no limit
+	MaxOpenConns int
+	// MaxIdleConns is the number of connections that are kept open when they are not used
+	MaxIdleConns int
+}
+
+// DefaultDriverOptions are the DriverOptions of the app when no flag changes them
+var DefaultDriverOptions = DriverOptions{
+	JournalMode:  JournalWAL,
+	Synchronous:  "NORMAL",
+	BusyTimeout:  5 * time.Second,
+	ForeignKeys:  true,
+	CacheSize:    -16000,
+	MaxOpenConns: 16,
+	MaxIdleConns: 8,
+}
+
+// Validate use for checking every option has a value that sqlite and the pool accept
+func (o DriverOptions) Validate() error {
+	switch strings.ToUpper(o.JournalMode) {
+	case JournalDelete, JournalTruncate, JournalPersist, JournalMemory, JournalWAL, JournalOff:
+	default:
+		return fmt.Errorf("%s is not a journal mode of sqlite", o.JournalMode)
+	}
+
+	if o.synchronousLevel() < 0 {
+		return fmt.Errorf("%s is not a synchronous level of sqlite; use OFF, NORMAL, FULL or EXTRA", o.Synchronous)
+	}
+
+	if o.BusyTimeout < 0 || o.MaxOpenConns < 0 || o.MaxIdleConns < 0 {
+		return errors.New("the busy timeout and the connection limits can not be negative")
+	}
+
+	return nil
+}
+
+// SQLiteDSN use for adding the sqlite options to the query of a sqlite dsn; they replace the same parameters
+// of the dsn and their aliases, so the checked settings are always the settings of the connections
+func (o DriverOptions) SQLiteDSN(dsn string) string {
+	path, rawQuery := dsn, ""
+	if i := strings.Index(dsn, "?"); i >= 0 {
+		path, rawQuery = dsn[:i], dsn[i+1:]
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		query = url.Values{}
+	}
+	for _, alias := range []string{"_journal", "_sync", "_timeout", "_fk"} {
+		query.Del(alias)
+	}
+
+	query.Set("_journal_mode", strings.ToUpper(o.JournalMode))
+	query.Set("_synchronous", strings.ToUpper(o.Synchronous))
+	query.Set("_busy_timeout", strconv.FormatInt(o.BusyTimeout.Milliseconds(), 10))
+	query.Set("_foreign_keys", strconv.FormatBool(o.ForeignKeys))
+	query.Set("_cache_size", strconv.Itoa(o.CacheSize))
+
+	return path + "?" + query.Encode()
+}
+
+// CheckOptions use for reading the settings of a sqlite connection back and comparing them with o;
+// a setting that sqlite ignored is returned as an error. It does nothing for postgres
+func (d *DBHolder) CheckOptions(ctx context.Context, o DriverOptions) error {
+	if d.Driver != DriverSQLite {
+		return nil
+	}
+
+	conn, err := d.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var journalMode string
+	var synchronous, busyTimeout, cacheSize int
+	var foreignKeys bool
+	pragmas := []struct {
+		name string
+		dest interface{}
+	}{
+		{"journal_mode", &journalMode},
+		{"synchronous", &synchronous},
+		{"busy_timeout", &busyTimeout},
+		{"foreign_keys", &foreignKeys},
+		{"cache_size", &cacheSize},
+	}
+	for _, p := range pragmas {
+		err = conn.QueryRowContext(ctx, "PRAGMA "+p.name).Scan(p.dest)
+		if err != nil {
+			return fmt.Errorf("reading PRAGMA %s: %w", p.name, err)
+		}
+	}
+
+	var mismatches []string
+	if !strings.EqualFold(journalMode, o.JournalMode) {
+		mismatches = append(mismatches, fmt.Sprintf("journal_mode is %s and not %s", journalMode, o.JournalMode))
+	}
+	if synchronous != o.synchronousLevel() {
+		mismatches = append(mismatches, fmt.Sprintf("synchronous is %d and not %s", synchronous, o.Synchronous))
+	}
+	if int64(busyTimeout) != o.BusyTimeout.Milliseconds() {
+		mismatches = append(mismatches, fmt.Sprintf("busy_timeout is %dms and not %s", busyTimeout, o.BusyTimeout))
+	}
+	if foreignKeys != o.ForeignKeys {
+		mismatches = append(mismatches, fmt.Sprintf("foreign_keys is %t and not %t", foreignKeys, o.ForeignKeys))
+	}
+	if cacheSize != o.CacheSize {
+		mismatches = append(mismatches, fmt.Sprintf("cache_size is %d and not %d", cacheSize, o.CacheSize))
+	}
+	if len(mismatches) > 0 {
+		return errors.New("sqlite did not apply the driver options: " + strings.Join(mismatches, ", "))
+	}
+
+	return nil
+}
+
+func (o DriverOptions) synchronousLevel() int {
+	for i, level := range synchronousLevels {
+		if strings.EqualFold(level, o.Synchronous) || o.Synchronous == strconv.Itoa(i) {
+			return i
+		}
+	}
+
+	return -1
+}
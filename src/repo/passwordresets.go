@@ -0,0 +1,101 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	zerolog "github.com/rs/zerolog/log"
+	"time"
+)
+
+// ErrInvalidResetToken returned when a password reset token is unknown, used or expired
+var ErrInvalidResetToken = errors.New("password reset token is invalid or expired")
+
+// PasswordResetOps holding the operations of the password reset tokens
+type PasswordResetOps interface {
+	AddPasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	ConsumePasswordReset(ctx context.Context, tokenHash string) (int, error)
+}
+
+// AddPasswordReset use for storing a reset token hash of a user; older unused tokens of the user become invalid
+func (d *DBHolder) AddPasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	if d.tx == nil {
+		return d.WithTx(ctx, func(tx Repo) error {
+			return tx.AddPasswordReset(ctx, userID, tokenHash, expiresAt)
+		})
+	}
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	now := time.Now().UTC()
+	query := `UPDATE password_resets SET used_at=? WHERE user_id=? AND used_at IS NULL`
+	_, err = d.tx.ExecContext(ctx, d.rebind(query), now, userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	query = `INSERT INTO password_resets (user_id,token_hash,created_at,expires_at) VALUES (?,?,?,?)`
+	_, err = d.tx.ExecContext(ctx, d.rebind(query), userID, tokenHash, now, expiresAt.UTC())
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// ConsumePasswordReset use for marking a reset token as used and returning its user; a token can be consumed once
+func (d *DBHolder) ConsumePasswordReset(ctx context.Context, tokenHash string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	if d.tx == nil {
+		var userID int
+		err := d.WithTx(ctx, func(tx Repo) error {
+			var err error
+			userID, err = tx.ConsumePasswordReset(ctx, tokenHash)
+			return err
+		})
+		return userID, err
+	}
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return 0, err
+	}
+
+	var id, userID int
+	var expiresAt time.Time
+	query := `SELECT id,user_id,expires_at FROM password_resets WHERE token_hash=? AND used_at IS NULL`
+	err = d.tx.QueryRowContext(ctx, d.rebind(query), tokenHash).Scan(&id, &userID, &expiresAt)
+	if err != nil {
+		return 0, ErrInvalidResetToken
+	}
+	if time.Now().After(expiresAt) {
+		return 0, ErrInvalidResetToken
+	}
+
+	query = `UPDATE password_resets SET used_at=? WHERE id=? AND used_at IS NULL`
+	result, err := d.tx.ExecContext(ctx, d.rebind(query), time.Now().UTC(), id)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, ErrInvalidResetToken
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	zerolog "github.com/rs/zerolog/log"
+	"time"
+)
+
+// ErrRefreshTokenUsed returned when a refresh token rotated before; it means the token is replayed
+var ErrRefreshTokenUsed = errors.New("refresh token is already used")
+
+// RefreshTokenOps holding the operations of the refresh tokens
+type RefreshTokenOps interface {
+	AddRefreshToken(ctx context.Context, token *models.RefreshToken, tokenHash string) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	UseRefreshToken(ctx context.Context, tokenID int) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+}
+
+// AddRefreshToken use for storing a refresh token by the sha256 hash of its token
+func (d *DBHolder) AddRefreshToken(ctx context.Context, token *models.RefreshToken, tokenHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	query := `INSERT INTO refresh_tokens (user_id,token_hash,family_id,mfa,created_at,expires_at) VALUES (?,?,?,?,?,?) RETURNING id`
+	err = d.db().QueryRowContext(ctx, d.rebind(query),
+		token.UserID,
+		tokenHash,
+		token.FamilyID,
+		token.MFA,
+		token.CreatedAt,
+		token.ExpiresAt,
+	).Scan(&token.ID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// GetRefreshTokenByHash use for finding a refresh token by the sha256 hash of its token
+func (d *DBHolder) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Read)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	token := &models.RefreshToken{}
+	var used, revoked sql.NullTime
+	query := `SELECT id,user_id,family_id,mfa,created_at,expires_at,used_at,revoked_at FROM refresh_tokens WHERE token_hash=?`
+	err = d.db().QueryRowContext(ctx, d.rebind(query), tokenHash).Scan(&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.MFA,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&used,
+		&revoked,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if used.Valid {
+		token.UsedAt = &used.Time
+	}
+	if revoked.Valid {
+		token.RevokedAt = &revoked.Time
+	}
+
+	return token, nil
+}
+
+// UseRefreshToken use for marking a refresh token as rotated; only one caller can use a token
+func (d *DBHolder) UseRefreshToken(ctx context.Context, tokenID int) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	query := `UPDATE refresh_tokens SET used_at=? WHERE id=? AND used_at IS NULL`
+	result, err := d.db().ExecContext(ctx, d.rebind(query), time.Now().UTC(), tokenID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+	if affected == 0 {
+		return ErrRefreshTokenUsed
+	}
+
+	return nil
+}
+
+// RevokeRefreshTokenFamily use for revoking a refresh token with every token that rotated from the same login
+func (d *DBHolder) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	query := `UPDATE refresh_tokens SET revoked_at=? WHERE family_id=? AND revoked_at IS NULL`
+	_, err := d.db().ExecContext(ctx, d.rebind(query), time.Now().UTC(), familyID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens use for revoking every refresh token of a user
+func (d *DBHolder) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	query := `UPDATE refresh_tokens SET revoked_at=? WHERE user_id=? AND revoked_at IS NULL`
+	_, err := d.db().ExecContext(ctx, d.rebind(query), time.Now().UTC(), userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
@@ -7,165 +7,220 @@ import (
 	"fmt"
 	"github.com/DapperBlondie/users-cars-systems/src/models"
 	zerolog "github.com/rs/zerolog/log"
+	"math"
+	"strings"
 	"time"
 )
 
 const (
-	CarsTable = `CREATE TABLE IF NOT EXISTS cars  
-( id integer NOT NULL PRIMARY KEY autoincrement , number_plate varchar(31) NOT NULL , color varchar(15) NOT NULL , vin varchar(31) NOT NULL , owner_id integer NOT NULL , CONSTRAINT vin_idx UNIQUE ( vin ) , CONSTRAINT num_idx UNIQUE ( number_plate ) , FOREIGN KEY ( owner_id ) REFERENCES users( id ) ON DELETE CASCADE ON UPDATE CASCADE )`
-
-	UsersTable = `CREATE TABLE IF NOT EXISTS users
-( id integer NOT NULL PRIMARY KEY autoincrement , com_name varchar(63) NOT NULL , sex boolean NOT NULL , birthday time NOT NULL DEFAULT CURRENT_TIME , password char(255) NOT NULL )`
-
-	GetUserCarsById = `SELECT r.id, r.number_plate, r.color, r.vin FROM users s INNER JOIN cars r ON r.owner_id = s.id WHERE s.id=?`
+	// BirthDayLayout is the ISO 8601 layout of the birthday of a new user
+	BirthDayLayout  = "2006-01-02"
+	GetUserCarsById = `SELECT id, number_plate, color, vin, owner_id FROM cars WHERE owner_id=? ORDER BY id`
+	GetUsersPage    = `SELECT u.id, u.com_name, u.sex, u.birthday, u.role, c.id, c.number_plate, c.color, c.vin, c.owner_id
+FROM (SELECT id, com_name, sex, birthday, role FROM users ORDER BY id LIMIT ? OFFSET ?) u
+LEFT JOIN cars c ON c.owner_id = u.id ORDER BY u.id, c.id`
 )
 
+// ApiOpsInterface holding every operation of a backend; the handlers depend on it and not on a database
 type ApiOpsInterface interface {
-	CreateTables() error
-	AddUser(user *models.Users) error
-	AddCar(car *models.Cars) error
-	UpdateUser(user *models.Users) error
-	UpdateCar(car *models.Cars) error
-	DeleteUser(userID int) error
-	GetUserByID(userID int) (*models.Users, error)
-	GetAllUsers(limit, offset int) ([]*models.Users, error)
+	ApiKeyOps
+	RefreshTokenOps
+	PasswordResetOps
+	TwoFactorOps
+	AuthFailureOps
+	WithTx(ctx context.Context, fn func(tx Repo) error) error
+	AddUser(ctx context.Context, user *models.Users) error
+	AddCar(ctx context.Context, car *models.Cars) error
+	UpdateUser(ctx context.Context, user *models.Users) error
+	UpdateCar(ctx context.Context, car *models.Cars) error
+	PatchUser(ctx context.Context, userID int, patch *models.UserPatch) (*models.Users, error)
+	PatchCar(ctx context.Context, carID int, patch *models.CarPatch) (*models.Cars, error)
+	DeleteUser(ctx context.Context, userID int) error
+	DeleteCar(ctx context.Context, carID int) error
+	GetUserByID(ctx context.Context, userID int) (*models.Users, error)
+	GetAllUsers(ctx context.Context, limit, offset int) ([]*models.Users, error)
+	GetUserPassword(ctx context.Context, userID int) (string, error)
+	UpdateUserPassword(ctx context.Context, userID int, password string) error
+	GetUserRole(ctx context.Context, userID int) (models.Role, error)
+	SetUserRole(ctx context.Context, userID int, role models.Role) error
+	GetCarByID(ctx context.Context, carID int) (*models.Cars, error)
 }
 
-// CreateTables use for creating our tables at the beginning of the program
-func (d *DBHolder) CreateTables() error {
-	err := d.PingingDB()
+// AddUser use for adding user into db
+func (d *DBHolder) AddUser(ctx context.Context, user *models.Users) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
 	if err != nil {
-		zerolog.Fatal().Msg(err.Error())
+		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-	_, err = d.DB.ExecContext(ctx, UsersTable)
+	birthDay, err := time.Parse(BirthDayLayout, user.BirthDay)
 	if err != nil {
-		zerolog.Fatal().Msg(err.Error())
-		return err
+		zerolog.Error().Msg(err.Error())
+		return Invalid("birth_day", "birth_day is not a date like "+BirthDayLayout)
 	}
 
-	_, err = d.DB.ExecContext(ctx, CarsTable)
+	if user.Role == "" {
+		user.Role = models.RoleOwner
+	}
+
+	stmt, err := d.stmt(addUserStmt)
 	if err != nil {
-		zerolog.Fatal().Msg(err.Error())
 		return err
 	}
 
+	err = stmt.QueryRowContext(ctx,
+		user.CompleteName, user.Sex, birthDay.Format(BirthDayLayout), user.Password, user.Role).Scan(&user.ID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return translate(err)
+	}
+
 	return nil
 }
 
-// AddUser use for adding user into db
-func (d *DBHolder) AddUser(user *models.Users) error {
-	err := d.PingingDB()
+// DeleteUser use for deleting a user with its own ID
+func (d *DBHolder) DeleteUser(ctx context.Context, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	birthDay, err := time.Parse("2006-07-02", user.BirthDay)
+	stmt, err := d.stmt(deleteUserStmt)
 	if err != nil {
-		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	stmtQ := `INSERT INTO users (com_name, sex, birthday, password) VALUES (?, ?, ?, ?)`
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*6)
-	defer cancel()
-
-	_, err = d.DB.ExecContext(ctx, stmtQ,
-		user.CompleteName, user.Sex, birthDay, user.Password)
+	result, err := stmt.ExecContext(ctx, userID)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	return nil
+	return affectedOne(result, NotFound("user"))
 }
 
-// DeleteUser use for deleting a user with its own ID
-func (d *DBHolder) DeleteUser(userID int) error {
-	err := d.PingingDB()
+// DeleteCar use for deleting a car with its own ID
+func (d *DBHolder) DeleteCar(ctx context.Context, carID int) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	stmtQ := `DELETE FROM users WHERE id=? `
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*6)
-	defer cancel()
+	stmt, err := d.stmt(deleteCarStmt)
+	if err != nil {
+		return err
+	}
 
-	_, err = d.DB.ExecContext(ctx, stmtQ, userID)
+	result, err := stmt.ExecContext(ctx, carID)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	return nil
+	return affectedOne(result, NotFound("car"))
 }
 
-// AddCar use for adding car into the db
-func (d *DBHolder) AddCar(car *models.Cars) error {
-	err := d.PingingDB()
+// AddCar use for adding car into the db; the owner check and the insert run in one transaction
+func (d *DBHolder) AddCar(ctx context.Context, car *models.Cars) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	if d.tx == nil {
+		return d.WithTx(ctx, func(tx Repo) error {
+			return tx.AddCar(ctx, car)
+		})
+	}
+
+	err := d.PingingDB(ctx)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	query := `SELECT EXISTS(SELECT * FROM users WHERE id=?);`
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
-	result := d.DB.QueryRowContext(ctx, query, car.OwnerID)
+	existsStmt, err := d.stmt(userExistsStmt)
+	if err != nil {
+		return err
+	}
+	insertStmt, err := d.stmt(addCarStmt)
+	if err != nil {
+		return err
+	}
 
-	var rs int
-	err = result.Scan(&rs)
+	var exists bool
+	err = existsStmt.QueryRowContext(ctx, car.OwnerID).Scan(&exists)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
-	if rs == 0 {
-		zerolog.Error().Msg(fmt.Sprintf("%d There is no car with this id=%d\n", rs, car.OwnerID))
-		return errors.New(fmt.Sprintf("There is no car with this id=%d\n", car.OwnerID))
+	if !exists {
+		zerolog.Error().Msg(fmt.Sprintf("there is no user with this id=%d", car.OwnerID))
+		return ForeignKeyError("owner_id", fmt.Sprintf("there is no user with this id=%d", car.OwnerID))
 	}
 
-	query = `INSERT INTO cars (number_plate,color,vin,owner_id) VALUES (?,?,?,?)`
-	_, err = d.DB.ExecContext(ctx, query,
-		car.NumberPlate, car.Color, car.VIN, car.OwnerID)
+	err = insertStmt.QueryRowContext(ctx,
+		car.NumberPlate, car.Color, car.VIN, car.OwnerID).Scan(&car.ID)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
-		return err
+		return translate(err)
 	}
 
 	return nil
 }
 
 // GetUserByID use for getting models.Users information with models.Cars
-func (d *DBHolder) GetUserByID(userID int) (*models.Users, error) {
-	err := d.PingingDB()
+func (d *DBHolder) GetUserByID(ctx context.Context, userID int) (*models.Users, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Read)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return nil, err
 	}
 
+	userStmt, err := d.stmt(getUserStmt)
+	if err != nil {
+		return nil, err
+	}
+	carsStmt, err := d.stmt(getUserCarsStmt)
+	if err != nil {
+		return nil, err
+	}
+
 	var user *models.Users = &models.Users{}
-	query := `SELECT id,com_name,sex,birthday FROM users WHERE id=?`
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-	defer cancel()
 
-	result := d.DB.QueryRowContext(ctx, query, userID)
+	result := userStmt.QueryRowContext(ctx, userID)
 	err = result.Scan(&user.ID,
 		&user.CompleteName,
 		&user.Sex,
 		&user.BirthDay,
+		&user.Role,
 	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, NotFound("user")
+	}
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return nil, err
 	}
 
-	results, err := d.DB.QueryContext(ctx, GetUserCarsById, userID)
+	results, err := carsStmt.QueryContext(ctx, userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
 	defer func(results *sql.Rows) {
 		err = results.Close()
 		if err != nil {
@@ -174,21 +229,14 @@ func (d *DBHolder) GetUserByID(userID int) (*models.Users, error) {
 		}
 	}(results)
 
-	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		return nil, err
-	}
-	if results == nil {
-		return user, nil
-	}
-
 	var cars []*models.Cars = []*models.Cars{}
-	car := &models.Cars{}
 	for results.Next() {
+		car := &models.Cars{}
 		err = results.Scan(&car.ID,
 			&car.NumberPlate,
 			&car.Color,
 			&car.VIN,
+			&car.OwnerID,
 		)
 		if err != nil {
 			zerolog.Error().Msg(err.Error())
@@ -197,26 +245,47 @@ func (d *DBHolder) GetUserByID(userID int) (*models.Users, error) {
 
 		cars = append(cars, car)
 	}
+	err = results.Err()
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
 
 	user.UsersCars = cars
 
 	return user, nil
 }
 
-// GetAllUsers use for getting all users and associated cars
-func (d *DBHolder) GetAllUsers(limit, offset int) ([]*models.Users, error) {
-	err := d.PingingDB()
+// GetAllUsers use for getting a page of users with their cars; the page and its cars are read by one query
+// and grouped by their users in memory
+func (d *DBHolder) GetAllUsers(ctx context.Context, limit, offset int) ([]*models.Users, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.List)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*25)
-	defer cancel()
+	stmt, err := d.stmt(getUsersPageStmt)
+	if err != nil {
+		return nil, err
+	}
 
-	var users []*models.Users
-	query := `SELECT id FROM users LIMIT ? OFFSET ?`
-	results, err := d.DB.QueryContext(ctx, query, limit, offset)
+	// postgres rejects a negative limit or offset; sqlite reads them as no limit and no offset
+	if limit < 0 {
+		limit = math.MaxInt32
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	results, err := stmt.QueryContext(ctx, limit, offset)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
 	defer func(results *sql.Rows) {
 		err := results.Close()
 		if err != nil {
@@ -225,82 +294,368 @@ func (d *DBHolder) GetAllUsers(limit, offset int) ([]*models.Users, error) {
 		}
 	}(results)
 
-	if err != nil {
-		zerolog.Error().Msg(err.Error())
-		return nil, err
-	}
-	if results == nil {
-		return nil, errors.New("there is no any data available about users")
-	}
-
-	user := &models.Users{}
+	var users []*models.Users
+	var user *models.Users
 	for results.Next() {
-		err = results.Scan(
-			&user.ID,
+		row := &models.Users{}
+		var carID, ownerID sql.NullInt64
+		var numberPlate, color, vin sql.NullString
+		err = results.Scan(&row.ID,
+			&row.CompleteName,
+			&row.Sex,
+			&row.BirthDay,
+			&row.Role,
+			&carID,
+			&numberPlate,
+			&color,
+			&vin,
+			&ownerID,
 		)
 		if err != nil {
 			zerolog.Error().Msg(err.Error())
 			return nil, err
 		}
 
-		user, err = d.GetUserByID(user.ID)
-		if err != nil {
-			return nil, err
+		// the rows are ordered by the user; so a new id starts the next user
+		if user == nil || user.ID != row.ID {
+			user = row
+			user.UsersCars = []*models.Cars{}
+			users = append(users, user)
+		}
+		if carID.Valid {
+			user.UsersCars = append(user.UsersCars, &models.Cars{
+				ID:          int(carID.Int64),
+				NumberPlate: numberPlate.String,
+				Color:       color.String,
+				VIN:         vin.String,
+				OwnerID:     int(ownerID.Int64),
+			})
 		}
-
-		users = append(users, user)
+	}
+	err = results.Err()
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
 	}
 
 	return users, nil
 }
 
-// UpdateUser use for update a user
-func (d *DBHolder) UpdateUser(user *models.Users) error {
-	err := d.PingingDB()
+// UpdateUser use for update the profile of a user; the password is changed by UpdateUserPassword
+func (d *DBHolder) UpdateUser(ctx context.Context, user *models.Users) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
-	query := `UPDATE users SET com_name=?,sex=?,birthday=?,password=? WHERE id=?`
-	_, err = d.DB.ExecContext(ctx, query,
+	stmt, err := d.stmt(updateUserStmt)
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx,
 		user.CompleteName,
 		user.Sex,
 		user.BirthDay,
-		user.Password,
 		user.ID)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
-		return err
+		return translate(err)
 	}
 
-	return nil
+	return affectedOne(result, NotFound("user"))
 }
 
 // UpdateCar use for update a car by its id
-func (d *DBHolder) UpdateCar(car *models.Cars) error {
-	err := d.PingingDB()
+func (d *DBHolder) UpdateCar(ctx context.Context, car *models.Cars) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
-	query := `UPDATE cars SET number_plate=?,color=?,vin=? WHERE id=?`
-	_, err = d.DB.ExecContext(ctx, query,
+	stmt, err := d.stmt(updateCarStmt)
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx,
 		car.NumberPlate,
 		car.Color,
 		car.VIN,
 		car.ID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return translate(err)
+	}
+
+	return affectedOne(result, NotFound("car"))
+}
+
+// affectedOne use for returning notFound when result did not change any row
+func affectedOne(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
 	if err != nil {
 		zerolog.Error().Msg(err.Error())
 		return err
 	}
+	if affected == 0 {
+		return notFound
+	}
 
 	return nil
 }
+
+// PatchUser use for changing only the fields of a user that patch has; the UPDATE is built from them
+// and it returns the updated user with its cars
+func (d *DBHolder) PatchUser(ctx context.Context, userID int, patch *models.UserPatch) (*models.Users, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	if d.tx == nil {
+		var user *models.Users
+		err := d.WithTx(ctx, func(tx Repo) error {
+			var err error
+			user, err = tx.PatchUser(ctx, userID, patch)
+			return err
+		})
+		return user, err
+	}
+
+	var set []string
+	var args []interface{}
+	if patch.CompleteName != nil {
+		set, args = append(set, "com_name=?"), append(args, *patch.CompleteName)
+	}
+	if patch.Sex != nil {
+		set, args = append(set, "sex=?"), append(args, *patch.Sex)
+	}
+	if patch.BirthDay != nil {
+		set, args = append(set, "birthday=?"), append(args, *patch.BirthDay)
+	}
+
+	err := d.patchRow(ctx, "users", userID, set, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.GetUserByID(ctx, userID)
+}
+
+// PatchCar use for changing only the fields of a car that patch has; the UPDATE is built from them
+// and it returns the updated car. Its new vin and number plate must be unique
+func (d *DBHolder) PatchCar(ctx context.Context, carID int, patch *models.CarPatch) (*models.Cars, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	if d.tx == nil {
+		var car *models.Cars
+		err := d.WithTx(ctx, func(tx Repo) error {
+			var err error
+			car, err = tx.PatchCar(ctx, carID, patch)
+			return err
+		})
+		return car, err
+	}
+
+	var set []string
+	var args []interface{}
+	if patch.NumberPlate != nil {
+		set, args = append(set, "number_plate=?"), append(args, *patch.NumberPlate)
+	}
+	if patch.Color != nil {
+		set, args = append(set, "color=?"), append(args, *patch.Color)
+	}
+	if patch.VIN != nil {
+		set, args = append(set, "vin=?"), append(args, *patch.VIN)
+	}
+
+	err := d.patchRow(ctx, "cars", carID, set, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.GetCarByID(ctx, carID)
+}
+
+// patchRow use for running UPDATE table SET set WHERE id=? in the transaction of d; the columns of set are
+// written by the repository, never by clients, and args are their values. An empty set does not run any query;
+// a row that does not exist is not an error here, the read of the caller reports it
+func (d *DBHolder) patchRow(ctx context.Context, table string, id int, set []string, args []interface{}) error {
+	if len(set) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id=?", table, strings.Join(set, ","))
+	_, err := d.tx.ExecContext(ctx, d.rebind(query), append(args, id)...)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return translate(err)
+	}
+
+	return nil
+}
+
+// GetUserPassword use for getting the stored password hash of a user for authentication
+func (d *DBHolder) GetUserPassword(ctx context.Context, userID int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Read)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return "", err
+	}
+
+	stmt, err := d.stmt(getUserPasswordStmt)
+	if err != nil {
+		return "", err
+	}
+	var password string
+	err = stmt.QueryRowContext(ctx, userID).Scan(&password)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", NoUser(userID)
+	}
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return "", translate(err)
+	}
+
+	return password, nil
+}
+
+// UpdateUserPassword use for replacing the password hash of a user
+func (d *DBHolder) UpdateUserPassword(ctx context.Context, userID int, password string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	stmt, err := d.stmt(updateUserPasswordStmt)
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, password, userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+	if affected == 0 {
+		return NoUser(userID)
+	}
+
+	return nil
+}
+
+// GetUserRole use for getting the role of a user for authorization
+func (d *DBHolder) GetUserRole(ctx context.Context, userID int) (models.Role, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Read)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return "", err
+	}
+
+	stmt, err := d.stmt(getUserRoleStmt)
+	if err != nil {
+		return "", err
+	}
+	var role models.Role
+	err = stmt.QueryRowContext(ctx, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", NoUser(userID)
+	}
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return "", translate(err)
+	}
+
+	return role, nil
+}
+
+// SetUserRole use for changing the role of a user
+func (d *DBHolder) SetUserRole(ctx context.Context, userID int, role models.Role) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	if !role.Valid() {
+		return Invalid("role", fmt.Sprintf("%s is not a valid role", role))
+	}
+
+	stmt, err := d.stmt(setUserRoleStmt)
+	if err != nil {
+		return err
+	}
+	result, err := stmt.ExecContext(ctx, role, userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+	if affected == 0 {
+		return NoUser(userID)
+	}
+
+	return nil
+}
+
+// GetCarByID use for getting a car by its id
+func (d *DBHolder) GetCarByID(ctx context.Context, carID int) (*models.Cars, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Read)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	stmt, err := d.stmt(getCarStmt)
+	if err != nil {
+		return nil, err
+	}
+	car := &models.Cars{}
+	err = stmt.QueryRowContext(ctx, carID).Scan(&car.ID,
+		&car.NumberPlate,
+		&car.Color,
+		&car.VIN,
+		&car.OwnerID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, NotFound("car")
+	}
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	return car, nil
+}
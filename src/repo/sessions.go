@@ -0,0 +1,164 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	zerolog "github.com/rs/zerolog/log"
+	"time"
+)
+
+// SessionStore is a scs.Store that keeps the sessions in the sessions table of our db
+type SessionStore struct {
+	DHolder     *DBHolder
+	stopCleanup chan bool
+	doneCleanup chan bool
+}
+
+// NewSessionStore use for creating a SessionStore; if cleanupInterval is greater than zero
+// a background job removes the expired sessions every cleanupInterval until Dispose is called
+func (d *DBHolder) NewSessionStore(cleanupInterval time.Duration) *SessionStore {
+	store := &SessionStore{
+		DHolder: d,
+	}
+
+	if cleanupInterval > 0 {
+		store.stopCleanup = make(chan bool)
+		store.doneCleanup = make(chan bool)
+		go store.startCleanup(cleanupInterval)
+	}
+	d.sessionStore = store
+
+	return store
+}
+
+// Find use for getting the data of a session token that is not expired
+func (s *SessionStore) Find(token string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.DHolder.Timeouts.Read)
+	defer cancel()
+
+	var data []byte
+	query := `SELECT data FROM sessions WHERE token=? AND expiry>?`
+	err := s.DHolder.DB.QueryRowContext(ctx, s.DHolder.rebind(query), token, time.Now().UnixNano()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Commit use for adding a session token or replacing its data and expiry
+func (s *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.DHolder.Timeouts.Write)
+	defer cancel()
+
+	query := `INSERT INTO sessions (token, data, expiry) VALUES (?, ?, ?)
+ON CONFLICT ( token ) DO UPDATE SET data=excluded.data , expiry=excluded.expiry`
+	_, err := s.DHolder.DB.ExecContext(ctx, s.DHolder.rebind(query), token, b, expiry.UnixNano())
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// Delete use for removing a session token
+func (s *SessionStore) Delete(token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.DHolder.Timeouts.Write)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE token=?`
+	_, err := s.DHolder.DB.ExecContext(ctx, s.DHolder.rebind(query), token)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// All use for getting the data of every session that is not expired by its token
+func (s *SessionStore) All() (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.DHolder.Timeouts.List)
+	defer cancel()
+
+	query := `SELECT token, data FROM sessions WHERE expiry>?`
+	results, err := s.DHolder.DB.QueryContext(ctx, s.DHolder.rebind(query), time.Now().UnixNano())
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+	defer func(results *sql.Rows) {
+		err := results.Close()
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+			return
+		}
+	}(results)
+
+	sessions := map[string][]byte{}
+	for results.Next() {
+		var token string
+		var data []byte
+		err = results.Scan(&token, &data)
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+			return nil, err
+		}
+		sessions[token] = data
+	}
+	err = results.Err()
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// deleteExpired use for removing every session that its expiry passed
+func (s *SessionStore) deleteExpired() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.DHolder.Timeouts.Write)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE expiry<=?`
+	_, err := s.DHolder.DB.ExecContext(ctx, s.DHolder.rebind(query), time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SessionStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer close(s.doneCleanup)
+
+	for {
+		select {
+		case <-ticker.C:
+			err := s.deleteExpired()
+			if err != nil {
+				zerolog.Error().Msg(err.Error())
+			}
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+// StopCleanup use for terminating the background cleanup job and waiting for it
+func (s *SessionStore) StopCleanup() {
+	if s.stopCleanup == nil {
+		return
+	}
+
+	close(s.stopCleanup)
+	<-s.doneCleanup
+	s.stopCleanup = nil
+}
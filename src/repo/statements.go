@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	zerolog "github.com/rs/zerolog/log"
+	"sync"
+)
+
+// Names of the prepared statements of the repository
+const (
+	addUserStmt            = "add_user"
+	deleteUserStmt         = "delete_user"
+	userExistsStmt         = "user_exists"
+	addCarStmt             = "add_car"
+	getUserStmt            = "get_user"
+	getUserCarsStmt        = "get_user_cars"
+	getUsersPageStmt       = "get_users_page"
+	updateUserStmt         = "update_user"
+	updateCarStmt          = "update_car"
+	getUserPasswordStmt    = "get_user_password"
+	updateUserPasswordStmt = "update_user_password"
+	getUserRoleStmt        = "get_user_role"
+	setUserRoleStmt        = "set_user_role"
+	getCarStmt             = "get_car"
+	deleteCarStmt          = "delete_car"
+)
+
+// statementQueries holding the queries that PrepareStatements prepares by their names
+var statementQueries = map[string]string{
+	addUserStmt:            `INSERT INTO users (com_name, sex, birthday, password, role) VALUES (?, ?, ?, ?, ?) RETURNING id`,
+	deleteUserStmt:         `DELETE FROM users WHERE id=? `,
+	userExistsStmt:         `SELECT EXISTS(SELECT * FROM users WHERE id=?);`,
+	addCarStmt:             `INSERT INTO cars (number_plate,color,vin,owner_id) VALUES (?,?,?,?) RETURNING id`,
+	getUserStmt:            `SELECT id,com_name,sex,birthday,role FROM users WHERE id=?`,
+	getUserCarsStmt:        GetUserCarsById,
+	getUsersPageStmt:       GetUsersPage,
+	updateUserStmt:         `UPDATE users SET com_name=?,sex=?,birthday=? WHERE id=?`,
+	updateCarStmt:          `UPDATE cars SET number_plate=?,color=?,vin=? WHERE id=?`,
+	getUserPasswordStmt:    `SELECT password FROM users WHERE id=?`,
+	updateUserPasswordStmt: `UPDATE users SET password=? WHERE id=?`,
+	getUserRoleStmt:        `SELECT role FROM users WHERE id=?`,
+	setUserRoleStmt:        `UPDATE users SET role=? WHERE id=?`,
+	getCarStmt:             `SELECT id,number_plate,color,vin,owner_id FROM cars WHERE id=?`,
+	deleteCarStmt:          `DELETE FROM cars WHERE id=?`,
+}
+
+// ErrStatementNotPrepared returned when a statement is used before it is prepared
+var ErrStatementNotPrepared = errors.New("statement is not prepared")
+
+// StatementRegistry holding prepared statements by their names; it is safe for concurrent use
+type StatementRegistry struct {
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+// NewStatementRegistry use for creating an empty StatementRegistry
+func NewStatementRegistry() *StatementRegistry {
+	return &StatementRegistry{stmts: map[string]*sql.Stmt{}}
+}
+
+// Add use for storing a statement by its name; a statement that had the same name is closed
+func (s *StatementRegistry) Add(name string, stmt *sql.Stmt) {
+	s.mu.Lock()
+	old := s.stmts[name]
+	s.stmts[name] = stmt
+	s.mu.Unlock()
+
+	if old != nil {
+		err := old.Close()
+		if err != nil {
+			zerolog.Error().Msg(err.Error())
+		}
+	}
+}
+
+// Get use for getting a prepared statement by its name
+func (s *StatementRegistry) Get(name string) (*sql.Stmt, error) {
+	s.mu.RLock()
+	stmt, ok := s.stmts[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStatementNotPrepared, name)
+	}
+
+	return stmt, nil
+}
+
+// Close use for closing every statement and emptying the registry
+func (s *StatementRegistry) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for name, stmt := range s.stmts {
+		err := stmt.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.stmts, name)
+	}
+
+	return firstErr
+}
+
+// PrepareStatements use for preparing every query of the repository once; it runs after the migrations
+// because sqlite can not prepare a query of a table that does not exist
+func (d *DBHolder) PrepareStatements(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	for name, query := range statementQueries {
+		err := d.CreateStatement(ctx, name, query)
+		if err != nil {
+			return fmt.Errorf("preparing %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// stmt use for getting a prepared statement of the repository; in a transaction the statement runs in it
+func (d *DBHolder) stmt(name string) (*sql.Stmt, error) {
+	stmt, err := d.Statements.Get(name)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return nil, err
+	}
+
+	if d.tx != nil {
+		return d.tx.Stmt(stmt), nil
+	}
+
+	return stmt, nil
+}
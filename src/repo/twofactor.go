@@ -0,0 +1,226 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	zerolog "github.com/rs/zerolog/log"
+	"time"
+)
+
+var (
+	// ErrTOTPEnabled returned when a user that already confirmed its totp tries to enroll again
+	ErrTOTPEnabled = errors.New("two factor authentication is already enabled")
+	// ErrTOTPReplayed returned when a time step is used again or an older one is used
+	ErrTOTPReplayed = errors.New("two factor code is already used")
+	// ErrRecoveryCodeInvalid returned when a recovery code is unknown or used
+	ErrRecoveryCodeInvalid = errors.New("recovery code is invalid")
+)
+
+// TwoFactorOps holding the operations of the totp enrollments and the recovery codes
+type TwoFactorOps interface {
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	GetTOTP(ctx context.Context, userID int) (*models.TOTP, error)
+	ConfirmTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+	DisableTOTP(ctx context.Context, userID int) error
+}
+
+// SetTOTPSecret use for storing a new unconfirmed totp secret of a user
+func (d *DBHolder) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	query := `INSERT INTO user_totp (user_id,secret,created_at) VALUES (?,?,?)
+ON CONFLICT ( user_id ) DO UPDATE SET secret=excluded.secret , created_at=excluded.created_at , last_step=0 WHERE user_totp.confirmed_at IS NULL`
+	result, err := d.db().ExecContext(ctx, d.rebind(query), userID, secret, time.Now().UTC())
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPEnabled
+	}
+
+	return nil
+}
+
+// GetTOTP use for getting the totp enrollment of a user; sql.ErrNoRows means the user never enrolled
+func (d *DBHolder) GetTOTP(ctx context.Context, userID int) (*models.TOTP, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Read)
+	defer cancel()
+
+	t := &models.TOTP{UserID: userID}
+	var confirmed sql.NullTime
+	query := `SELECT secret,confirmed_at,last_step FROM user_totp WHERE user_id=?`
+	err := d.db().QueryRowContext(ctx, d.rebind(query), userID).Scan(&t.Secret, &confirmed, &t.LastStep)
+	if err != nil {
+		return nil, err
+	}
+	t.Confirmed = confirmed.Valid
+
+	return t, nil
+}
+
+// ConfirmTOTP use for enabling the totp of a user and replacing its recovery codes
+func (d *DBHolder) ConfirmTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	if d.tx == nil {
+		return d.WithTx(ctx, func(tx Repo) error {
+			return tx.ConfirmTOTP(ctx, userID, step, codeHashes)
+		})
+	}
+
+	err := d.PingingDB(ctx)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	query := `UPDATE user_totp SET confirmed_at=?, last_step=? WHERE user_id=? AND confirmed_at IS NULL`
+	result, err := d.tx.ExecContext(ctx, d.rebind(query), time.Now().UTC(), step, userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPEnabled
+	}
+
+	err = d.replaceRecoveryCodes(ctx, userID, codeHashes)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes use for generating a new set of recovery codes; the old ones become invalid
+func (d *DBHolder) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	if d.tx == nil {
+		return d.WithTx(ctx, func(tx Repo) error {
+			return tx.ReplaceRecoveryCodes(ctx, userID, codeHashes)
+		})
+	}
+
+	err := d.replaceRecoveryCodes(ctx, userID, codeHashes)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// replaceRecoveryCodes use for replacing the recovery codes of a user in the transaction of d
+func (d *DBHolder) replaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	_, err := d.tx.ExecContext(ctx, d.rebind(`DELETE FROM recovery_codes WHERE user_id=?`), userID)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range codeHashes {
+		_, err = d.tx.ExecContext(ctx, d.rebind(`INSERT INTO recovery_codes (user_id,code_hash) VALUES (?,?)`), userID, h)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseTOTPStep use for recording the time step of an accepted code; older or equal steps are rejected
+func (d *DBHolder) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	query := `UPDATE user_totp SET last_step=? WHERE user_id=? AND last_step<?`
+	result, err := d.db().ExecContext(ctx, d.rebind(query), step, userID, step)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPReplayed
+	}
+
+	return nil
+}
+
+// UseRecoveryCode use for consuming a recovery code of a user
+func (d *DBHolder) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	query := `UPDATE recovery_codes SET used_at=? WHERE user_id=? AND code_hash=? AND used_at IS NULL`
+	result, err := d.db().ExecContext(ctx, d.rebind(query), time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+
+	return nil
+}
+
+// DisableTOTP use for removing the totp and recovery codes of a user
+func (d *DBHolder) DisableTOTP(ctx context.Context, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeouts.Write)
+	defer cancel()
+
+	if d.tx == nil {
+		return d.WithTx(ctx, func(tx Repo) error {
+			return tx.DisableTOTP(ctx, userID)
+		})
+	}
+
+	_, err := d.tx.ExecContext(ctx, d.rebind(`DELETE FROM recovery_codes WHERE user_id=?`), userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	_, err = d.tx.ExecContext(ctx, d.rebind(`DELETE FROM user_totp WHERE user_id=?`), userID)
+	if err != nil {
+		zerolog.Error().Msg(err.Error())
+		return err
+	}
+
+	return nil
+}
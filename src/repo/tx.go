@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	zerolog "github.com/rs/zerolog/log"
+	"time"
+)
+
+const (
+	txAttempts  = 5
+	txBaseDelay = 10 * time.Millisecond
+)
+
+// Repo is the ApiOpsInterface that WithTx passes to its function; its operations run in the transaction
+type Repo interface {
+	ApiOpsInterface
+}
+
+// WithTx use for running fn in a transaction; the transaction is committed when fn returns nil and rolled back
+// when fn returns an error or panics. A busy or locked database runs fn again in a new transaction,
+// so fn should not have side effects out of the Repo that it gets. Calling WithTx in fn joins the same transaction.
+func (d *DBHolder) WithTx(ctx context.Context, fn func(tx Repo) error) error {
+	if d.tx != nil {
+		return fn(d)
+	}
+
+	var err error
+	for attempt := 1; attempt <= txAttempts; attempt++ {
+		err = d.runTx(ctx, fn)
+		if !isBusy(err) {
+			return err
+		}
+
+		zerolog.Warn().Msg(fmt.Sprintf("database is busy; retrying the transaction (%d/%d)", attempt, txAttempts))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(txBaseDelay << (attempt - 1)):
+		}
+	}
+
+	return err
+}
+
+func (d *DBHolder) runTx(ctx context.Context, fn func(tx Repo) error) (err error) {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			rbErr := tx.Rollback()
+			if rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				zerolog.Error().Msg(rbErr.Error())
+			}
+		}
+	}()
+
+	err = fn(&DBHolder{DB: d.DB, Driver: d.Driver, Statements: d.Statements, Timeouts: d.Timeouts, tx: tx})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// isBusy use for checking an error is returned because another connection holds the lock of the database;
+// for postgres it is a serialization failure or a deadlock that aborted the transaction
+func isBusy(err error) bool {
+	if isSQLiteBusy(err) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/config"
+	"github.com/DapperBlondie/users-cars-systems/src/passwords"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"testing"
+)
+
+func TestLoginRehashesOutdatedPasswords(t *testing.T) {
+	argon := config.New().Password.Argon2idParams()
+	argon.Memory = 64
+	argon.Time = 1
+
+	cases := []struct {
+		name     string
+		alg      string
+		cost     int
+		password string
+		rehash   bool
+	}{
+		{"bcrypt of the current cost", passwords.AlgBcrypt, bcrypt.MinCost, testPassword, false},
+		{"bcrypt of another cost", passwords.AlgBcrypt, bcrypt.MinCost + 1, testPassword, true},
+		{"argon2id", passwords.AlgArgon2id, bcrypt.MinCost, testPassword, true},
+		{"bcrypt of another cost by a wrong password", passwords.AlgBcrypt, bcrypt.MinCost + 1, "wrong-secret-42", false},
+	}
+
+	for _, c := range cases {
+		srv, store := newTestServer(t, &resetNotifier{})
+		user := addTestUser(t, store)
+
+		hasher, err := passwords.NewHasher(c.alg, c.cost, argon)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored, err := hasher.Hash(testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		err = store.UpdateUserPassword(ctx, user.ID, stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status := send(t, http.DefaultClient, srv, http.MethodPost, "/login", fmt.Sprintf(`{"user_id":%d,"password":"%s"}`, user.ID, c.password))
+		if c.password == testPassword && status != http.StatusOK {
+			t.Fatalf("%s: login answered %d", c.name, status)
+		}
+
+		got, err := store.GetUserPassword(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.rehash {
+			if got != stored {
+				t.Errorf("%s: the stored hash is replaced", c.name)
+			}
+			continue
+		}
+
+		cost, err := bcrypt.Cost([]byte(got))
+		if err != nil || cost != bcrypt.MinCost {
+			t.Errorf("%s: the stored hash %s is not a bcrypt hash of the cost %d", c.name, got, bcrypt.MinCost)
+		}
+		status = send(t, http.DefaultClient, srv, http.MethodPost, "/login", fmt.Sprintf(`{"user_id":%d,"password":"%s"}`, user.ID, testPassword))
+		if status != http.StatusOK {
+			t.Errorf("%s: login by the new hash answered %d", c.name, status)
+		}
+	}
+}
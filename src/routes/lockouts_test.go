@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/config"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/memory"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// lockUser use for recording the failures that lock user out with the default lockout settings
+func lockUser(t *testing.T, store *memory.Store, user *models.Users) {
+	lockout := config.New().Lockout
+	for i := 0; i < lockout.MaxUserFailures; i++ {
+		_, err := store.RecordAuthFailure(context.Background(), models.FailureKindUser, strconv.Itoa(user.ID),
+			lockout.MaxUserFailures, lockout.LockoutDuration, lockout.FailureWindow)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLockedUserIsRejectedBeforeTheHash(t *testing.T) {
+	srv, store := newTestServer(t, &resetNotifier{})
+	user := addTestUser(t, store)
+	lockUser(t, store, user)
+
+	// a stored hash that can not be parsed fails the login when it is compared
+	ctx := context.Background()
+	err := store.UpdateUserPassword(ctx, user.ID, "not-a-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/login",
+		strings.NewReader(fmt.Sprintf(`{"user_id":%d,"password":"%s"}`, user.ID, testPassword)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("login of a locked user answered %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("login of a locked user answered no Retry-After")
+	}
+
+	f, err := store.GetAuthFailure(ctx, models.FailureKindUser, strconv.Itoa(user.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Failures != config.New().Lockout.MaxUserFailures {
+		t.Errorf("the rejected login changed the failures to %d", f.Failures)
+	}
+}
+
+func TestAdminUnlock(t *testing.T) {
+	srv, store := newTestServer(t, &resetNotifier{})
+	admin := addTestUser(t, store)
+	err := store.SetUserRole(context.Background(), admin.ID, models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminClient := login(t, srv, admin)
+
+	user := addTestUser(t, store)
+	lockUser(t, store, user)
+
+	credentials := fmt.Sprintf(`{"user_id":%d,"password":"%s"}`, user.ID, testPassword)
+	status := send(t, http.DefaultClient, srv, http.MethodPost, "/login", credentials)
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("login of a locked user answered %d", status)
+	}
+
+	status = send(t, login(t, srv, addTestUser(t, store)), srv, http.MethodPost, "/unlock", fmt.Sprintf(`{"user_id":%d}`, user.ID))
+	if status != http.StatusForbidden {
+		t.Errorf("unlock by an owner answered %d", status)
+	}
+
+	status, data := sendRead(t, adminClient, srv, http.MethodPost, "/unlock", fmt.Sprintf(`{"user_id":%d}`, user.ID))
+	if status != http.StatusOK {
+		t.Fatalf("unlock answered %d: %s", status, data)
+	}
+
+	status = send(t, http.DefaultClient, srv, http.MethodPost, "/login", credentials)
+	if status != http.StatusOK {
+		t.Errorf("login after the unlock answered %d", status)
+	}
+}
@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/handlers"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/memory"
+	"net/http"
+	"testing"
+)
+
+// permissionFixture is a caller of a role with a car, and another owner with a car
+type permissionFixture struct {
+	actor, other      *models.Users
+	ownCar, othersCar *models.Cars
+}
+
+// newPermissionFixture use for storing the users and the cars of a permissionFixture; actor gets role
+func newPermissionFixture(t *testing.T, store *memory.Store, role models.Role) *permissionFixture {
+	ctx := context.Background()
+	f := &permissionFixture{actor: addTestUser(t, store), other: addTestUser(t, store)}
+
+	err := store.SetUserRole(ctx, f.actor.ID, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.ownCar = &models.Cars{NumberPlate: "OWN 1", Color: "red", VIN: "1HGCM82693A000001", OwnerID: f.actor.ID}
+	f.othersCar = &models.Cars{NumberPlate: "OTHER 1", Color: "red", VIN: "1HGCM82603A000002", OwnerID: f.other.ID}
+	for _, car := range []*models.Cars{f.ownCar, f.othersCar} {
+		err = store.AddCar(ctx, car)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return f
+}
+
+func TestRolePermissions(t *testing.T) {
+	user := func(id int) string { return fmt.Sprintf("%s/users/%d", handlers.APIPrefix, id) }
+	car := func(id int) string { return fmt.Sprintf("%s/cars/%d", handlers.APIPrefix, id) }
+	profile := `{"complete_name":"Bo Ray","sex":false,"birth_day":"1980-01-01"}`
+	newCar := `{"number_plate":"NEW 1","color":"blue","vin":"1HGCM82623A000003"}`
+
+	cases := []struct {
+		name    string
+		request func(f *permissionFixture) (method, path, body string)
+		// the statuses that an owner, a fleet manager and an admin get
+		owner, fleetManager, admin int
+	}{
+		{"patch the user of another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPatch, user(f.other.ID), `{"complete_name":"Bo Ray"}`
+		}, http.StatusForbidden, http.StatusForbidden, http.StatusOK},
+		{"put the user of another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPut, user(f.other.ID), profile
+		}, http.StatusForbidden, http.StatusForbidden, http.StatusOK},
+		{"delete the user of another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodDelete, user(f.other.ID), ""
+		}, http.StatusForbidden, http.StatusForbidden, http.StatusNoContent},
+		{"legacy update of the user of another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPost, "/update-user", fmt.Sprintf(`{"id":%d,"complete_name":"Bo Ray","birth_day":"1980-01-01"}`, f.other.ID)
+		}, http.StatusForbidden, http.StatusForbidden, http.StatusOK},
+		{"legacy delete of the user of another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodGet, fmt.Sprintf("/delete-user?user_id=%d", f.other.ID), ""
+		}, http.StatusForbidden, http.StatusForbidden, http.StatusOK},
+		{"patch the own user", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPatch, user(f.actor.ID), `{"complete_name":"Bo Ray"}`
+		}, http.StatusOK, http.StatusOK, http.StatusOK},
+		{"add a car to another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPost, user(f.other.ID) + "/cars", newCar
+		}, http.StatusForbidden, http.StatusCreated, http.StatusCreated},
+		{"patch the car of another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPatch, car(f.othersCar.ID), `{"color":"blue"}`
+		}, http.StatusForbidden, http.StatusOK, http.StatusOK},
+		{"delete the car of another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodDelete, car(f.othersCar.ID), ""
+		}, http.StatusForbidden, http.StatusNoContent, http.StatusNoContent},
+		{"legacy add of a car to another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPost, "/add-car", fmt.Sprintf(`{"number_plate":"NEW 1","color":"blue","vin":"1HGCM82623A000003","owner_id":%d}`, f.other.ID)
+		}, http.StatusForbidden, http.StatusOK, http.StatusOK},
+		{"legacy update of the car of another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPost, "/update-car", fmt.Sprintf(`{"id":%d,"number_plate":"OTHER 1","color":"blue","vin":"1HGCM82603A000002"}`, f.othersCar.ID)
+		}, http.StatusForbidden, http.StatusOK, http.StatusOK},
+		{"add a car to the own user", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPost, user(f.actor.ID) + "/cars", newCar
+		}, http.StatusCreated, http.StatusCreated, http.StatusCreated},
+		{"patch the own car", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPatch, car(f.ownCar.ID), `{"color":"blue"}`
+		}, http.StatusOK, http.StatusOK, http.StatusOK},
+		{"set the role of another", func(f *permissionFixture) (string, string, string) {
+			return http.MethodPost, "/set-role", fmt.Sprintf(`{"user_id":%d,"role":"fleet_manager"}`, f.other.ID)
+		}, http.StatusForbidden, http.StatusForbidden, http.StatusOK},
+	}
+
+	for _, c := range cases {
+		for _, role := range []models.Role{models.RoleOwner, models.RoleFleetManager, models.RoleAdmin} {
+			want := map[models.Role]int{models.RoleOwner: c.owner, models.RoleFleetManager: c.fleetManager, models.RoleAdmin: c.admin}[role]
+
+			srv, store := newTestServer(t, &resetNotifier{})
+			f := newPermissionFixture(t, store, role)
+			client := login(t, srv, f.actor)
+
+			method, path, body := c.request(f)
+			status, data := sendRead(t, client, srv, method, path, body)
+			if status != want {
+				t.Errorf("%s by %s: %s %s answered %d, want %d: %s", c.name, role, method, path, status, want, data)
+			}
+
+			// a denied request changes nothing
+			if status == http.StatusForbidden {
+				stored, err := store.GetUserByID(context.Background(), f.other.ID)
+				if err != nil {
+					t.Fatalf("%s by %s: %v", c.name, role, err)
+				}
+				if stored.CompleteName != f.other.CompleteName || stored.Role != models.RoleOwner || len(stored.UsersCars) != 1 ||
+					stored.UsersCars[0].Color != f.othersCar.Color {
+					t.Errorf("%s by %s: the denied request changed the other user to %+v", c.name, role, stored)
+				}
+			}
+		}
+	}
+}
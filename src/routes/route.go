@@ -2,23 +2,108 @@ package routes
 
 import (
 	"github.com/DapperBlondie/users-cars-systems/src/handlers"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
 	"github.com/go-chi/chi"
 	"net/http"
 )
 
 func ApiRoutes() http.Handler {
 	mux := chi.NewRouter()
+	mux.NotFound(handlers.NotFoundHandler)
+	mux.MethodNotAllowed(handlers.MethodNotAllowedHandler)
 
 	mux.Use(handlers.ApiConf.EnableCORS)
+	mux.Use(handlers.ApiConf.ScsManager.LoadAndSave)
 	mux.Get("/status", handlers.ApiConf.CheckStatus)
-	mux.Get("/delete-user", handlers.ApiConf.DeleteUserHandler)
-	mux.Get("/get-user/{user_id}", handlers.ApiConf.GetUserHandler)
-	mux.Get("/get-all-users", handlers.ApiConf.GetAllUsersHandler)
 
-	mux.Post("/add-user", handlers.ApiConf.AddUserHandler)
-	mux.Post("/add-car", handlers.ApiConf.AddCarHandler)
-	mux.Post("/update-user", handlers.ApiConf.UpdateUserHandler)
-	mux.Post("/update-car", handlers.ApiConf.UpdateCarHandler)
+	mux.With(handlers.ApiConf.RequireSessionMode).Post("/login", handlers.ApiConf.LoginHandler)
+	mux.With(handlers.ApiConf.RequireSessionMode).Post("/login/2fa", handlers.ApiConf.LoginTwoFactorHandler)
+	mux.With(handlers.ApiConf.RequireSessionMode).Post("/logout", handlers.ApiConf.LogoutHandler)
+
+	mux.With(handlers.ApiConf.RequireTokenMode).Post("/token", handlers.ApiConf.TokenHandler)
+	mux.With(handlers.ApiConf.RequireTokenMode).Post("/refresh-token", handlers.ApiConf.RefreshTokenHandler)
+	mux.With(handlers.ApiConf.RequireTokenMode).Post("/revoke-token", handlers.ApiConf.RevokeTokenHandler)
+
+	mux.Post("/password/forgot", handlers.ApiConf.ForgotPasswordHandler)
+	mux.Post("/password/reset", handlers.ApiConf.ResetPasswordHandler)
+
+	mux.Group(func(mux chi.Router) {
+		mux.Use(handlers.ApiConf.RequireAuth)
+
+		usersWrite := handlers.ApiConf.RequireScope(models.ScopeUsersWrite)
+
+		mux.Get("/me", handlers.ApiConf.MeHandler)
+		mux.With(usersWrite).Post("/users/{user_id}/password", handlers.ApiConf.ChangePasswordHandler)
+
+		mux.Post("/2fa/enroll", handlers.ApiConf.EnrollTwoFactorHandler)
+		mux.Post("/2fa/confirm", handlers.ApiConf.ConfirmTwoFactorHandler)
+		mux.Post("/2fa/recovery-codes", handlers.ApiConf.RecoveryCodesHandler)
+		mux.Post("/2fa/disable", handlers.ApiConf.DisableTwoFactorHandler)
+
+		mux.Group(func(mux chi.Router) {
+			mux.Use(handlers.ApiConf.RequireStaffMFA)
+
+			mux.With(usersWrite).Post("/set-role", handlers.ApiConf.SetRoleHandler)
+
+			mux.Post("/add-api-key", handlers.ApiConf.AddApiKeyHandler)
+			mux.Get("/get-api-keys", handlers.ApiConf.GetApiKeysHandler)
+			mux.Post("/revoke-api-key", handlers.ApiConf.RevokeApiKeyHandler)
+
+			mux.Get("/lockouts", handlers.ApiConf.GetLockoutsHandler)
+			mux.Post("/unlock", handlers.ApiConf.UnlockHandler)
+
+			mux.Post("/backup", handlers.ApiConf.BackupHandler)
+			mux.Get("/backups", handlers.ApiConf.GetBackupsHandler)
+		})
+	})
+
+	// legacy routes of the users and cars; they are replaced by the routes of handlers.APIPrefix
+	mux.Group(func(mux chi.Router) {
+		mux.Use(handlers.ApiConf.Deprecated)
+
+		mux.Get("/get-user/{user_id}", handlers.ApiConf.GetUserHandler)
+		mux.Get("/get-all-users", handlers.ApiConf.GetAllUsersHandler)
+		mux.Post("/add-user", handlers.ApiConf.AddUserHandler)
+
+		mux.Group(func(mux chi.Router) {
+			mux.Use(handlers.ApiConf.RequireAuth)
+			mux.Use(handlers.ApiConf.RequireStaffMFA)
+
+			usersWrite := handlers.ApiConf.RequireScope(models.ScopeUsersWrite)
+			carsWrite := handlers.ApiConf.RequireScope(models.ScopeCarsWrite)
+
+			mux.With(usersWrite).Get("/delete-user", handlers.ApiConf.DeleteUserHandler)
+			mux.With(carsWrite).Post("/add-car", handlers.ApiConf.AddCarHandler)
+			mux.With(usersWrite).Post("/update-user", handlers.ApiConf.UpdateUserHandler)
+			mux.With(carsWrite).Post("/update-car", handlers.ApiConf.UpdateCarHandler)
+		})
+	})
+
+	mux.Route(handlers.APIPrefix, apiV1Routes)
 
 	return mux
 }
+
+// apiV1Routes use for mounting the users and cars resources of handlers.APIPrefix
+func apiV1Routes(mux chi.Router) {
+	mux.Get("/users", handlers.ApiConf.ListUsersHandler)
+	mux.Post("/users", handlers.ApiConf.CreateUserHandler)
+	mux.Get("/users/{user_id}", handlers.ApiConf.GetUserHandler)
+	mux.Get("/users/{user_id}/cars", handlers.ApiConf.GetUserCarsHandler)
+	mux.Get("/cars/{car_id}", handlers.ApiConf.GetCarHandler)
+
+	mux.Group(func(mux chi.Router) {
+		mux.Use(handlers.ApiConf.RequireAuth)
+		mux.Use(handlers.ApiConf.RequireStaffMFA)
+
+		usersWrite := handlers.ApiConf.RequireScope(models.ScopeUsersWrite)
+		carsWrite := handlers.ApiConf.RequireScope(models.ScopeCarsWrite)
+
+		mux.With(usersWrite).Patch("/users/{user_id}", handlers.ApiConf.PatchUserHandler)
+		mux.With(usersWrite).Put("/users/{user_id}", handlers.ApiConf.PutUserHandler)
+		mux.With(usersWrite).Delete("/users/{user_id}", handlers.ApiConf.RemoveUserHandler)
+		mux.With(carsWrite).Post("/users/{user_id}/cars", handlers.ApiConf.CreateUserCarHandler)
+		mux.With(carsWrite).Patch("/cars/{car_id}", handlers.ApiConf.PatchCarHandler)
+		mux.With(carsWrite).Delete("/cars/{car_id}", handlers.ApiConf.RemoveCarHandler)
+	})
+}
@@ -0,0 +1,372 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/config"
+	"github.com/DapperBlondie/users-cars-systems/src/handlers"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"github.com/DapperBlondie/users-cars-systems/src/notify"
+	"github.com/DapperBlondie/users-cars-systems/src/passwords"
+	"github.com/DapperBlondie/users-cars-systems/src/repo/memory"
+	"github.com/DapperBlondie/users-cars-systems/src/tokens"
+	"github.com/alexedwards/scs/v2"
+	"golang.org/x/crypto/bcrypt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPassword = "longsecret42"
+
+// newTestServer starts the routes on the in memory backend with notifier; staff two factor authentication
+// is off so a logged in admin reaches every route
+func newTestServer(t *testing.T, notifier notify.Notifier) (*httptest.Server, *memory.Store) {
+	conf := config.New()
+	conf.Auth.RequireStaffMFA = false
+
+	signer, err := tokens.NewSigner(conf.Auth.TokenAlg, "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := passwords.NewPolicy(conf.Password.MinLength, false, true, true, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher, err := passwords.NewHasher(passwords.AlgBcrypt, bcrypt.MinCost, conf.Password.Argon2idParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.NewStore()
+	sessions := scs.New()
+	sessions.Store = store.NewSessionStore(0)
+	handlers.NewApiConf(sessions, store, conf.Auth, conf.Lockout, tokens.NewManager(signer, conf.Auth.AccessTTL),
+		notifier, policy, hasher, nil)
+
+	srv := httptest.NewServer(ApiRoutes())
+	t.Cleanup(srv.Close)
+
+	return srv, store
+}
+
+// secretKeys are the parts of the json keys that a response must never have
+var secretKeys = []string{"password", "hash"}
+
+// findSecretKeys returns the keys of v and of its nested objects that have a secret part
+func findSecretKeys(v interface{}) []string {
+	var found []string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, nested := range v {
+			for _, secret := range secretKeys {
+				if strings.Contains(strings.ToLower(k), secret) {
+					found = append(found, k)
+				}
+			}
+			found = append(found, findSecretKeys(nested)...)
+		}
+	case []interface{}:
+		for _, nested := range v {
+			found = append(found, findSecretKeys(nested)...)
+		}
+	}
+
+	return found
+}
+
+func TestResponsesHaveNoPassword(t *testing.T) {
+	srv, store := newTestServer(t, notify.NewLogNotifier())
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar, Timeout: 10 * time.Second}
+
+	var hashes []string
+	do := func(method, path, contentType, body string) []byte {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode >= 300 {
+			t.Fatalf("%s %s answered %d: %s", method, path, resp.StatusCode, data)
+		}
+
+		if len(data) > 0 {
+			var v interface{}
+			err = json.Unmarshal(data, &v)
+			if err != nil {
+				t.Fatalf("%s %s answered invalid json: %v", method, path, err)
+			}
+			if keys := findSecretKeys(v); len(keys) > 0 {
+				t.Errorf("%s %s answered the keys %v: %s", method, path, keys, data)
+			}
+		}
+		for _, h := range append(hashes, testPassword, "$2a$") {
+			if bytes.Contains(data, []byte(h)) {
+				t.Errorf("%s %s answered a password or its hash: %s", method, path, data)
+			}
+		}
+
+		return data
+	}
+
+	user := `{"complete_name":"Ann Lee","sex":true,"birth_day":"1990-03-04","password":"` + testPassword + `"}`
+	var created models.UserView
+	err = json.Unmarshal(do(http.MethodPost, handlers.APIPrefix+"/users", "application/json", user), &created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do(http.MethodPost, "/add-user", "application/json", strings.Replace(user, "Ann Lee", "Bo Ray", 1))
+
+	ctx := context.Background()
+	err = store.SetUserRole(ctx, created.ID, models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{created.ID, created.ID + 1} {
+		h, err := store.GetUserPassword(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, h)
+	}
+
+	do(http.MethodPost, "/login", "application/json", fmt.Sprintf(`{"user_id":%d,"password":"%s"}`, created.ID, testPassword))
+
+	users := fmt.Sprintf("%s/users/%d", handlers.APIPrefix, created.ID)
+	var car models.CarView
+	err = json.Unmarshal(do(http.MethodPost, users+"/cars", "application/json",
+		`{"number_plate":"AB-123","color":"red","vin":"1M8GDM9AXKP042788"}`), &car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cars := fmt.Sprintf("%s/cars/%d", handlers.APIPrefix, car.ID)
+
+	do(http.MethodPost, "/add-car", "application/json",
+		fmt.Sprintf(`{"number_plate":"ZZ 9","color":"red","vin":"1HGCM82633A004352","owner_id":%d}`, created.ID+1))
+	do(http.MethodGet, "/me", "", "")
+	do(http.MethodGet, handlers.APIPrefix+"/users", "", "")
+	do(http.MethodGet, users, "", "")
+	do(http.MethodGet, users+"/cars", "", "")
+	do(http.MethodPatch, users, handlers.MergePatchContentType, `{"complete_name":"Ann Leigh"}`)
+	do(http.MethodPut, users, "application/json", `{"complete_name":"Ann Lee","sex":true,"birth_day":"1990-03-04","password":"ignored1234"}`)
+	do(http.MethodGet, cars, "", "")
+	do(http.MethodPatch, cars, handlers.MergePatchContentType, `{"color":"blue"}`)
+	do(http.MethodGet, fmt.Sprintf("/get-user/%d", created.ID), "", "")
+	do(http.MethodGet, "/get-all-users?limit=10&offset=0", "", "")
+	do(http.MethodPost, "/update-user", "application/json",
+		fmt.Sprintf(`{"id":%d,"complete_name":"Bo Ray","birth_day":"1980-01-01"}`, created.ID+1))
+	do(http.MethodPost, "/update-car", "application/json",
+		fmt.Sprintf(`{"id":%d,"number_plate":"AB-123","color":"green","vin":"1M8GDM9AXKP042788"}`, car.ID))
+	do(http.MethodDelete, cars, "", "")
+}
+
+// resetNotifier keeps the last reset token that it is sent; a non nil err fails every notification
+type resetNotifier struct {
+	token string
+	err   error
+}
+
+func (n *resetNotifier) NotifyPasswordReset(reset *notify.PasswordReset) error {
+	n.token = reset.Token
+	return n.err
+}
+
+// addTestUser use for storing an owner whose password is testPassword
+func addTestUser(t *testing.T, store *memory.Store) *models.Users {
+	hasher, err := passwords.NewHasher(passwords.AlgBcrypt, bcrypt.MinCost, config.New().Password.Argon2idParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.Users{CompleteName: "Ann Lee", BirthDay: "1990-03-04", Role: models.RoleOwner}
+	user.Password, err = hasher.Hash(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.AddUser(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// send use for sending body to the path of srv by client and getting the status of the response
+func send(t *testing.T, client *http.Client, srv *httptest.Server, method, path, body string) int {
+	t.Helper()
+	status, _ := sendRead(t, client, srv, method, path, body)
+
+	return status
+}
+
+// sendRead use for sending body to the path of srv by client and getting the status and the body of the response
+func sendRead(t *testing.T, client *http.Client, srv *httptest.Server, method, path, body string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.StatusCode, data
+}
+
+// login use for logging user in with testPassword by a new client that keeps the session cookie
+func login(t *testing.T, srv *httptest.Server, user *models.Users) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar, Timeout: 10 * time.Second}
+
+	status, data := sendRead(t, client, srv, http.MethodPost, "/login", fmt.Sprintf(`{"user_id":%d,"password":"%s"}`, user.ID, testPassword))
+	if status != http.StatusOK {
+		t.Fatalf("login of the user %d answered %d: %s", user.ID, status, data)
+	}
+
+	return client
+}
+
+func TestResetPasswordLogsOut(t *testing.T) {
+	notifier := &resetNotifier{}
+	srv, store := newTestServer(t, notifier)
+	user := addTestUser(t, store)
+
+	var clients []*http.Client
+	for i := 0; i < 2; i++ {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Jar: jar, Timeout: 10 * time.Second}
+		status := send(t, client, srv, http.MethodPost, "/login", fmt.Sprintf(`{"user_id":%d,"password":"%s"}`, user.ID, testPassword))
+		if status != http.StatusOK {
+			t.Fatalf("login answered %d", status)
+		}
+		clients = append(clients, client)
+	}
+
+	status := send(t, http.DefaultClient, srv, http.MethodPost, "/password/forgot", fmt.Sprintf(`{"user_id":%d}`, user.ID))
+	if status != http.StatusOK || notifier.token == "" {
+		t.Fatalf("forgot password answered %d and sent the token %q", status, notifier.token)
+	}
+	status = send(t, http.DefaultClient, srv, http.MethodPost, "/password/reset",
+		fmt.Sprintf(`{"token":"%s","new_password":"another-secret-77"}`, notifier.token))
+	if status != http.StatusOK {
+		t.Fatalf("reset password answered %d", status)
+	}
+
+	for i, client := range clients {
+		status = send(t, client, srv, http.MethodGet, "/me", "")
+		if status != http.StatusUnauthorized {
+			t.Errorf("the session %d answered %d after the password is reset", i, status)
+		}
+	}
+}
+
+func TestForgotPasswordHidesFailures(t *testing.T) {
+	notifier := &resetNotifier{err: errors.New("the mail server is down")}
+	srv, store := newTestServer(t, notifier)
+	user := addTestUser(t, store)
+
+	status, known := sendRead(t, http.DefaultClient, srv, http.MethodPost, "/password/forgot", fmt.Sprintf(`{"user_id":%d}`, user.ID))
+	if status != http.StatusOK {
+		t.Fatalf("forgot password of a user answered %d after the notifier failed: %s", status, known)
+	}
+	status, unknown := sendRead(t, http.DefaultClient, srv, http.MethodPost, "/password/forgot", fmt.Sprintf(`{"user_id":%d}`, user.ID+100))
+	if status != http.StatusOK {
+		t.Fatalf("forgot password of an unknown user answered %d: %s", status, unknown)
+	}
+	if !bytes.Equal(known, unknown) {
+		t.Errorf("a user and an unknown user are answered differently: %s and %s", known, unknown)
+	}
+}
+
+func TestAuthenticatedResponsesAreNotCached(t *testing.T) {
+	srv, store := newTestServer(t, notify.NewLogNotifier())
+	user := addTestUser(t, store)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar, Timeout: 10 * time.Second}
+	status := send(t, client, srv, http.MethodPost, "/login", fmt.Sprintf(`{"user_id":%d,"password":"%s"}`, user.ID, testPassword))
+	if status != http.StatusOK {
+		t.Fatalf("login answered %d", status)
+	}
+	status, data := sendRead(t, client, srv, http.MethodPost, "/add-api-key", `{"name":"ci"}`)
+	if status != http.StatusCreated && status != http.StatusOK {
+		t.Fatalf("add-api-key answered %d: %s", status, data)
+	}
+	var issued models.IssuedApiKey
+	err = json.Unmarshal(data, &issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct {
+		name          string
+		client        *http.Client
+		authorization string
+	}{
+		{"session", client, ""},
+		{"api key", http.DefaultClient, "Bearer " + issued.Key},
+	}
+	for _, r := range requests {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/me", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.authorization != "" {
+			req.Header.Set("Authorization", r.authorization)
+		}
+		resp, err := r.client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("/me by the %s answered %d", r.name, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Cache-Control"); got != "no-store" {
+			t.Errorf("/me by the %s answered Cache-Control %q", r.name, got)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/handlers"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sendPatch use for sending a PATCH of body with contentType to the path of srv by client
+func sendPatch(t *testing.T, client *http.Client, srv *httptest.Server, path, contentType, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPatch, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp, data
+}
+
+func TestMergePatch(t *testing.T) {
+	srv, store := newTestServer(t, &resetNotifier{})
+	user := addTestUser(t, store)
+	other := addTestUser(t, store)
+	client := login(t, srv, user)
+
+	ctx := context.Background()
+	car := &models.Cars{NumberPlate: "OWN 1", Color: "red", VIN: "1HGCM82693A000001", OwnerID: user.ID}
+	taken := &models.Cars{NumberPlate: "TAKEN 1", Color: "red", VIN: "1HGCM82603A000002", OwnerID: other.ID}
+	for _, c := range []*models.Cars{car, taken} {
+		err := store.AddCar(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	userPath := fmt.Sprintf("%s/users/%d", handlers.APIPrefix, user.ID)
+	carPath := fmt.Sprintf("%s/cars/%d", handlers.APIPrefix, car.ID)
+
+	cases := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"a field of a user", userPath, handlers.MergePatchContentType, `{"complete_name":"Ann Leigh"}`, http.StatusOK},
+		{"a field of a car", carPath, handlers.MergePatchContentType, `{"color":"blue"}`, http.StatusOK},
+		{"a json body", carPath, "application/json; charset=utf-8", `{"color":"green"}`, http.StatusOK},
+		{"an empty patch", carPath, handlers.MergePatchContentType, `{}`, http.StatusOK},
+		{"a text body", carPath, "text/plain", `{"color":"black"}`, http.StatusUnsupportedMediaType},
+		{"a json patch of RFC 6902", carPath, "application/json-patch+json", `[{"op":"replace","path":"/color","value":"black"}]`, http.StatusUnsupportedMediaType},
+		{"no content type", carPath, "", `{"color":"black"}`, http.StatusUnsupportedMediaType},
+		{"a patch that is not an object", carPath, handlers.MergePatchContentType, `["color"]`, http.StatusBadRequest},
+		{"invalid json", carPath, handlers.MergePatchContentType, `{"color":`, http.StatusBadRequest},
+		// every field of the users and the cars is required, so null can not remove any of them
+		{"null of a required field", carPath, handlers.MergePatchContentType, `{"vin":null}`, http.StatusUnprocessableEntity},
+		{"null of a bool", userPath, handlers.MergePatchContentType, `{"sex":null}`, http.StatusUnprocessableEntity},
+		{"a field that can not be changed", carPath, handlers.MergePatchContentType, fmt.Sprintf(`{"owner_id":%d}`, other.ID), http.StatusUnprocessableEntity},
+		{"a value that breaks a rule", carPath, handlers.MergePatchContentType, `{"number_plate":"own 1"}`, http.StatusUnprocessableEntity},
+		{"the plate of another car", carPath, handlers.MergePatchContentType, `{"number_plate":"TAKEN 1"}`, http.StatusConflict},
+		{"the vin of another car", carPath, handlers.MergePatchContentType, `{"vin":"1HGCM82603A000002","color":"pink"}`, http.StatusConflict},
+	}
+
+	for _, c := range cases {
+		resp, data := sendPatch(t, client, srv, c.path, c.contentType, c.body)
+		if resp.StatusCode != c.status {
+			t.Errorf("%s: PATCH %s answered %d, want %d: %s", c.name, c.path, resp.StatusCode, c.status, data)
+		}
+		if c.status == http.StatusUnsupportedMediaType && resp.Header.Get("Accept-Patch") != handlers.MergePatchContentType {
+			t.Errorf("%s: answered Accept-Patch %q", c.name, resp.Header.Get("Accept-Patch"))
+		}
+	}
+
+	// only the patched fields changed and the rejected patches changed nothing
+	stored, err := store.GetCarByID(ctx, car.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.Cars{ID: car.ID, NumberPlate: car.NumberPlate, Color: "green", VIN: car.VIN, OwnerID: user.ID}
+	if *stored != want {
+		t.Errorf("the car is %+v, want %+v", *stored, want)
+	}
+
+	storedUser, err := store.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedUser.CompleteName != "Ann Leigh" || storedUser.BirthDay != user.BirthDay || storedUser.Sex != user.Sex || storedUser.Role != user.Role {
+		t.Errorf("the user is %+v", storedUser)
+	}
+}
@@ -0,0 +1,224 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Issuer is the iss claim of every token that we sign
+const Issuer = "users-cars-systems"
+
+var (
+	ErrMalformed = errors.New("token is malformed")
+	ErrSignature = errors.New("token signature is invalid")
+	ErrExpired   = errors.New("token is expired")
+)
+
+// Signer signs and verifies the header and payload of a JWT
+type Signer interface {
+	Alg() string
+	Sign(data []byte) []byte
+	Verify(data, sig []byte) bool
+}
+
+type hmacSigner struct {
+	secret []byte
+}
+
+// NewHMACSigner use for creating an HS256 Signer
+func NewHMACSigner(secret []byte) Signer {
+	return &hmacSigner{secret: secret}
+}
+
+func (h *hmacSigner) Alg() string { return "HS256" }
+
+func (h *hmacSigner) Sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (h *hmacSigner) Verify(data, sig []byte) bool {
+	return hmac.Equal(h.Sign(data), sig)
+}
+
+type ed25519Signer struct {
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// NewEd25519Signer use for creating an EdDSA Signer
+func NewEd25519Signer(private ed25519.PrivateKey) Signer {
+	return &ed25519Signer{
+		private: private,
+		public:  private.Public().(ed25519.PublicKey),
+	}
+}
+
+func (e *ed25519Signer) Alg() string { return "EdDSA" }
+
+func (e *ed25519Signer) Sign(data []byte) []byte {
+	return ed25519.Sign(e.private, data)
+}
+
+func (e *ed25519Signer) Verify(data, sig []byte) bool {
+	return ed25519.Verify(e.public, data, sig)
+}
+
+// Claims is the payload of our access tokens
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   int    `json:"sub"`
+	Role      string `json:"role"`
+	MFA       bool   `json:"mfa"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// Manager use for issuing and parsing signed access tokens
+type Manager struct {
+	signer Signer
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewManager use for creating a Manager that issues tokens valid for ttl
+func NewManager(signer Signer, ttl time.Duration) *Manager {
+	return &Manager{
+		signer: signer,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// TTL is the lifetime of the issued tokens
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Issue use for signing an access token for a user; mfa tells the user passed two factor authentication
+func (m *Manager) Issue(userID int, role string, mfa bool) (string, error) {
+	now := m.now()
+	claims := &Claims{
+		Issuer:    Issuer,
+		Subject:   userID,
+		Role:      role,
+		MFA:       mfa,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(m.ttl).Unix(),
+	}
+
+	h, err := json.Marshal(&header{Alg: m.signer.Alg(), Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	p, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+	sig := m.signer.Sign([]byte(signingInput))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Parse use for verifying an access token and returning its claims
+func (m *Manager) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	hb, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	h := &header{}
+	err = json.Unmarshal(hb, h)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	// the alg must be ours; otherwise a token could choose how it is verified
+	if h.Alg != m.signer.Alg() {
+		return nil, ErrSignature
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !m.signer.Verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrSignature
+	}
+
+	pb, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	claims := &Claims{}
+	err = json.Unmarshal(pb, claims)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if claims.Issuer != Issuer {
+		return nil, ErrSignature
+	}
+	if m.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpired
+	}
+
+	return claims, nil
+}
+
+// NewSigner use for creating the Signer of alg from secret; an empty secret means a random key
+// that lives until the program exits
+func NewSigner(alg, secret string) (Signer, error) {
+	switch alg {
+	case "HS256":
+		if secret == "" {
+			key := make([]byte, 32)
+			_, err := rand.Read(key)
+			if err != nil {
+				return nil, err
+			}
+
+			return NewHMACSigner(key), nil
+		}
+
+		return NewHMACSigner([]byte(secret)), nil
+	case "EdDSA":
+		if secret == "" {
+			_, private, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				return nil, err
+			}
+
+			return NewEd25519Signer(private), nil
+		}
+
+		seed, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, errors.New("EdDSA secret must be a base64 encoded 32 bytes seed")
+		}
+
+		return NewEd25519Signer(ed25519.NewKeyFromSeed(seed)), nil
+	}
+
+	return nil, errors.New(alg + " is not a supported token algorithm")
+}
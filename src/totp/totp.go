@@ -0,0 +1,142 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Algorithms of the HMAC that RFC 6238 allows
+const (
+	AlgSHA1   = "SHA1"
+	AlgSHA256 = "SHA256"
+	AlgSHA512 = "SHA512"
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Options holding the parameters of the one-time passwords; authenticator apps only support the defaults well
+type Options struct {
+	Digits    int
+	Period    int64
+	Algorithm string
+	Hash      func() hash.Hash
+	// Skew is the number of periods before and after now that are accepted
+	Skew int64
+}
+
+// DefaultOptions are SHA1 with 6 digits every 30 seconds and one period of skew
+var DefaultOptions = &Options{
+	Digits:    6,
+	Period:    30,
+	Algorithm: AlgSHA1,
+	Hash:      sha1.New,
+	Skew:      1,
+}
+
+// GenerateSecret use for creating a random 160 bits secret in base32
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return b32.EncodeToString(b), nil
+}
+
+// DecodeSecret use for decoding a base32 secret; spaces and lower case letters are accepted
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+
+	return b32.DecodeString(secret)
+}
+
+// HOTP use for computing the RFC 4226 one-time password of a counter
+func HOTP(key []byte, counter uint64, digits int, h func() hash.Hash) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(h, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// Counter use for getting the RFC 6238 time step of t
+func (o *Options) Counter(t time.Time) int64 {
+	return t.Unix() / o.Period
+}
+
+// Generate use for computing the one-time password of a base32 secret at t
+func (o *Options) Generate(secret string, t time.Time) (string, error) {
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return HOTP(key, uint64(o.Counter(t)), o.Digits, o.Hash), nil
+}
+
+// Validate use for checking a code at t within the skew; it returns the matched time step
+// so callers can reject a code that is used again
+func (o *Options) Validate(secret, code string, t time.Time) (int64, error) {
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != o.Digits {
+		return 0, ErrInvalidCode
+	}
+
+	now := o.Counter(t)
+	for step := now - o.Skew; step <= now+o.Skew; step++ {
+		if step < 0 {
+			continue
+		}
+		expected := HOTP(key, uint64(step), o.Digits, o.Hash)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+
+	return 0, ErrInvalidCode
+}
+
+// URI use for building the otpauth uri that authenticator apps scan as a qr code
+func (o *Options) URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", o.Algorithm)
+	v.Set("digits", fmt.Sprint(o.Digits))
+	v.Set("period", fmt.Sprint(o.Period))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// ErrInvalidCode returned when a one-time password does not match
+var ErrInvalidCode = errors.New("two factor code is invalid")
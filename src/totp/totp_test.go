@@ -0,0 +1,90 @@
+package totp
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"testing"
+	"time"
+)
+
+// rfc6238Vectors are the test vectors of RFC 6238 Appendix B; every algorithm has its own ASCII seed
+// and the codes have 8 digits with a period of 30 seconds
+var rfc6238Vectors = []struct {
+	unix   int64
+	sha1   string
+	sha256 string
+	sha512 string
+}{
+	{59, "94287082", "46119246", "90693936"},
+	{1111111109, "07081804", "68084774", "25091201"},
+	{1111111111, "14050471", "67062674", "99943326"},
+	{1234567890, "89005924", "91819424", "93441116"},
+	{2000000000, "69279037", "90698825", "38618901"},
+	{20000000000, "65353130", "77737706", "47863826"},
+}
+
+func TestRFC6238Vectors(t *testing.T) {
+	algorithms := []struct {
+		name string
+		seed string
+		hash func() hash.Hash
+		code func(i int) string
+	}{
+		{AlgSHA1, "12345678901234567890", sha1.New, func(i int) string { return rfc6238Vectors[i].sha1 }},
+		{AlgSHA256, "12345678901234567890123456789012", sha256.New, func(i int) string { return rfc6238Vectors[i].sha256 }},
+		{AlgSHA512, "1234567890123456789012345678901234567890123456789012345678901234", sha512.New, func(i int) string { return rfc6238Vectors[i].sha512 }},
+	}
+
+	for _, alg := range algorithms {
+		o := &Options{Digits: 8, Period: 30, Algorithm: alg.name, Hash: alg.hash}
+		secret := b32.EncodeToString([]byte(alg.seed))
+
+		for i, v := range rfc6238Vectors {
+			at := time.Unix(v.unix, 0).UTC()
+			want := alg.code(i)
+
+			got, err := o.Generate(secret, at)
+			if err != nil {
+				t.Fatalf("%s at %d: %v", alg.name, v.unix, err)
+			}
+			if got != want {
+				t.Errorf("%s at %d: got %s, want %s", alg.name, v.unix, got, want)
+			}
+
+			step, err := o.Validate(secret, want, at)
+			if err != nil || step != v.unix/30 {
+				t.Errorf("%s at %d: Validate returned step %d and %v", alg.name, v.unix, step, err)
+			}
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1234567890, 0)
+
+	previous, err := DefaultOptions.Generate(secret, now.Add(-30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DefaultOptions.Validate(secret, previous, now)
+	if err != nil {
+		t.Errorf("the code of the previous period is rejected: %v", err)
+	}
+
+	old, err := DefaultOptions.Generate(secret, now.Add(-90*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old != previous {
+		_, err = DefaultOptions.Validate(secret, old, now)
+		if err != ErrInvalidCode {
+			t.Errorf("a code out of the skew returned %v", err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DapperBlondie/users-cars-systems/src/models"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DateLayout is the ISO 8601 layout of the dates that the date rule accepts
+const DateLayout = "2006-01-02"
+
+// Errors holding every field of a value that broke a rule
+type Errors []*models.FieldError
+
+func (e Errors) Error() string {
+	var parts []string
+	for _, f := range e {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// Rule use for checking the value of a field by the argument of its tag; it returns the message of the violation
+// or an empty string
+type Rule func(v reflect.Value, arg string) string
+
+// Rules holding the rules that the validate tags can name; a tag like `validate:"required,max=15"` runs
+// required and then max with 15. The rules of a field stop at its first violation
+var Rules = map[string]Rule{
+	"required": required,
+	"min":      minLength,
+	"max":      maxLength,
+	"date":     date,
+	"vin":      vin,
+	"plate":    plate,
+}
+
+// Struct use for checking the fields of a struct or of a pointer to it by their validate tags;
+// it returns every violation at once and nil when there is none. The field names are their json names.
+// A nil pointer field is not in the value, like a field that a patch does not change, so it is not checked
+func Struct(s interface{}) Errors {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				continue
+			}
+			value = value.Elem()
+		}
+
+		field := fieldName(t.Field(i))
+		for _, rule := range strings.Split(tag, ",") {
+			name, arg := rule, ""
+			if eq := strings.Index(rule, "="); eq >= 0 {
+				name, arg = rule[:eq], rule[eq+1:]
+			}
+
+			check, ok := Rules[name]
+			if !ok {
+				panic(fmt.Sprintf("validate: %s of %s.%s is not a rule", name, t.Name(), t.Field(i).Name))
+			}
+
+			message := check(value, arg)
+			if message != "" {
+				errs = append(errs, &models.FieldError{Field: field, Rule: name, Message: field + " " + message})
+				break
+			}
+		}
+	}
+
+	return errs
+}
+
+// Patch use for checking the members of a JSON merge patch of RFC 7396 against patch, the struct that it is
+// decoded into; a member that patch does not have can not be changed and a null member would remove its field
+func Patch(members map[string]json.RawMessage, patch interface{}) Errors {
+	fields := map[string]bool{}
+	t := reflect.Indirect(reflect.ValueOf(patch)).Type()
+	for i := 0; i < t.NumField(); i++ {
+		fields[fieldName(t.Field(i))] = true
+	}
+
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs Errors
+	for _, name := range names {
+		if !fields[name] {
+			errs = append(errs, &models.FieldError{Field: name, Rule: "patchable", Message: name + " can not be changed"})
+		} else if string(members[name]) == "null" {
+			errs = append(errs, &models.FieldError{Field: name, Rule: "required", Message: name + " can not be removed"})
+		}
+	}
+
+	return errs
+}
+
+// fieldName use for getting the json name of a field
+func fieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return f.Name
+	}
+
+	return name
+}
+
+func required(v reflect.Value, arg string) string {
+	if v.Kind() == reflect.String {
+		if strings.TrimSpace(v.String()) == "" {
+			return "is required"
+		}
+	} else if v.IsZero() {
+		return "is required"
+	}
+
+	return ""
+}
+
+func minLength(v reflect.Value, arg string) string {
+	n, _ := strconv.Atoi(arg)
+	if utf8.RuneCountInString(v.String()) < n {
+		return fmt.Sprintf("must be at least %d characters", n)
+	}
+
+	return ""
+}
+
+func maxLength(v reflect.Value, arg string) string {
+	n, _ := strconv.Atoi(arg)
+	if utf8.RuneCountInString(v.String()) > n {
+		return fmt.Sprintf("must be at most %d characters", n)
+	}
+
+	return ""
+}
+
+// date use for checking a real ISO 8601 date like 1990-01-02 that is not in the future
+func date(v reflect.Value, arg string) string {
+	d, err := time.Parse(DateLayout, v.String())
+	if err != nil {
+		return "must be a date like " + DateLayout
+	}
+	if d.After(time.Now().UTC()) {
+		return "can not be in the future"
+	}
+
+	return ""
+}
+
+var vinChars = regexp.MustCompile(`^[A-HJ-NPR-Z0-9]{17}$`)
+
+// vinWeights are the weights of the positions of a VIN for its check digit
+var vinWeights = [17]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// vin use for checking a VIN of ISO 3779; 17 upper case letters and digits without I, O and Q
+// whose 9th character is the check digit
+func vin(v reflect.Value, arg string) string {
+	s := v.String()
+	if !vinChars.MatchString(s) {
+		return "must be 17 upper case letters and digits without I, O and Q"
+	}
+	if s[8] != vinCheckDigit(s) {
+		return "has a wrong check digit"
+	}
+
+	return ""
+}
+
+// vinCheckDigit use for calculating the check digit of a VIN; the letters are transliterated to their values,
+// weighted by their positions and the sum modulo 11 is the digit, where 10 is X
+func vinCheckDigit(s string) byte {
+	sum := 0
+	for i := 0; i < len(s); i++ {
+		sum += vinValue(s[i]) * vinWeights[i]
+	}
+
+	if sum%11 == 10 {
+		return 'X'
+	}
+	return byte('0' + sum%11)
+}
+
+func vinValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'A' && c <= 'H':
+		return int(c-'A') + 1
+	case c >= 'J' && c <= 'R':
+		return int(c-'J')%9 + 1
+	default:
+		// S is 2 and every next letter is one more
+		return int(c-'S') + 2
+	}
+}
+
+var plateFormat = regexp.MustCompile(`^[A-Z0-9]+([ -][A-Z0-9]+)*$`)
+
+// plate use for checking a number plate; upper case letters and digits that single spaces or hyphens can separate
+func plate(v reflect.Value, arg string) string {
+	if !plateFormat.MatchString(v.String()) {
+		return "must be upper case letters and digits separated by single spaces or hyphens"
+	}
+
+	return ""
+}
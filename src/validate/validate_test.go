@@ -0,0 +1,111 @@
+package validate
+
+import (
+	"testing"
+)
+
+// ruled has a field for every rule; validRuled gives a value that breaks none of them
+type ruled struct {
+	Name     string `json:"name" validate:"required,min=2,max=5"`
+	Birthday string `json:"birthday" validate:"date"`
+	Plate    string `json:"number_plate" validate:"plate"`
+	VIN      string `json:"vin" validate:"vin"`
+}
+
+func validRuled() ruled {
+	return ruled{Name: "Anna", Birthday: "1990-01-02", Plate: "AB-123", VIN: "1HGCM82633A004352"}
+}
+
+func TestRules(t *testing.T) {
+	cases := []struct {
+		name  string
+		set   func(r *ruled)
+		field string
+		rule  string
+	}{
+		{"valid", func(r *ruled) {}, "", ""},
+		{"vin with a digit check digit", func(r *ruled) { r.VIN = "1HGCM82633A004352" }, "", ""},
+		{"vin with x check digit", func(r *ruled) { r.VIN = "1M8GDM9AXKP042788" }, "", ""},
+		{"vin with a changed digit", func(r *ruled) { r.VIN = "1HGCM82633A004353" }, "vin", "vin"},
+		{"vin with a changed model year", func(r *ruled) { r.VIN = "1HGCM82634A004352" }, "vin", "vin"},
+		{"vin with I", func(r *ruled) { r.VIN = "1HGCM82633AI04352" }, "vin", "vin"},
+		{"vin with O", func(r *ruled) { r.VIN = "1HGCM82633AO04352" }, "vin", "vin"},
+		{"vin with Q", func(r *ruled) { r.VIN = "1HGCM82633AQ04352" }, "vin", "vin"},
+		{"vin in lower case", func(r *ruled) { r.VIN = "1hgcm82633a004352" }, "vin", "vin"},
+		{"vin of 16 characters", func(r *ruled) { r.VIN = "1HGCM82633A00435" }, "vin", "vin"},
+		{"vin of 18 characters", func(r *ruled) { r.VIN = "1HGCM82633A0043520" }, "vin", "vin"},
+		{"empty vin", func(r *ruled) { r.VIN = "" }, "vin", "vin"},
+		{"date of a leap day", func(r *ruled) { r.Birthday = "2000-02-29" }, "", ""},
+		{"date that does not exist", func(r *ruled) { r.Birthday = "1990-02-30" }, "birthday", "date"},
+		{"date in another layout", func(r *ruled) { r.Birthday = "02/01/1990" }, "birthday", "date"},
+		{"date with a time", func(r *ruled) { r.Birthday = "1990-01-02T00:00:00Z" }, "birthday", "date"},
+		{"date in the future", func(r *ruled) { r.Birthday = "2999-01-01" }, "birthday", "date"},
+		{"plate with a space", func(r *ruled) { r.Plate = "AB 123" }, "", ""},
+		{"plate without a separator", func(r *ruled) { r.Plate = "AB123" }, "", ""},
+		{"plate in lower case", func(r *ruled) { r.Plate = "ab-123" }, "number_plate", "plate"},
+		{"plate with two hyphens", func(r *ruled) { r.Plate = "AB--123" }, "number_plate", "plate"},
+		{"plate with a trailing space", func(r *ruled) { r.Plate = "AB-123 " }, "number_plate", "plate"},
+		{"plate with a symbol", func(r *ruled) { r.Plate = "AB_123" }, "number_plate", "plate"},
+		{"name of min characters", func(r *ruled) { r.Name = "Al" }, "", ""},
+		{"name of max characters", func(r *ruled) { r.Name = "Alice" }, "", ""},
+		{"name of max multibyte characters", func(r *ruled) { r.Name = "Åsa Ö" }, "", ""},
+		{"name under min", func(r *ruled) { r.Name = "A" }, "name", "min"},
+		{"name over max", func(r *ruled) { r.Name = "Alexis" }, "name", "max"},
+		{"blank name", func(r *ruled) { r.Name = "   " }, "name", "required"},
+	}
+
+	for _, c := range cases {
+		r := validRuled()
+		c.set(&r)
+
+		errs := Struct(&r)
+		if c.field == "" {
+			if errs != nil {
+				t.Errorf("%s: got %v, want no error", c.name, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 {
+			t.Errorf("%s: got %d errors (%v), want 1", c.name, len(errs), errs)
+			continue
+		}
+		if errs[0].Field != c.field || errs[0].Rule != c.rule {
+			t.Errorf("%s: got %s of %s, want %s of %s", c.name, errs[0].Rule, errs[0].Field, c.rule, c.field)
+		}
+	}
+}
+
+func TestStructCollectsEveryField(t *testing.T) {
+	r := validRuled()
+	r.Name = ""
+	r.VIN = "1HGCM82633A004353"
+
+	errs := Struct(r)
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors (%v), want 2", len(errs), errs)
+	}
+	if errs[0].Field != "name" || errs[0].Rule != "required" {
+		t.Errorf("got %s of %s first, want required of name", errs[0].Rule, errs[0].Field)
+	}
+	if errs[1].Field != "vin" || errs[1].Rule != "vin" {
+		t.Errorf("got %s of %s second, want vin of vin", errs[1].Rule, errs[1].Field)
+	}
+
+	want := "name: name is required; vin: vin has a wrong check digit"
+	if errs.Error() != want {
+		t.Errorf("got %q, want %q", errs.Error(), want)
+	}
+}
+
+func TestStructSkipsNilPointers(t *testing.T) {
+	type patch struct {
+		Plate *string `json:"number_plate" validate:"required,plate"`
+		VIN   *string `json:"vin" validate:"required,vin"`
+	}
+
+	bad := "1HGCM82633A004353"
+	errs := Struct(&patch{VIN: &bad})
+	if len(errs) != 1 || errs[0].Field != "vin" {
+		t.Errorf("got %v, want only the error of vin", errs)
+	}
+}